@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables which override the
+// config, e.g. TRANSLATE_CLI_OPENAI_API_KEY for openai.api_key
+const EnvPrefix = "TRANSLATE_CLI"
+
+// userFile is the path of the user config file, it may not exist
+var userFile string
+
+// DefaultUserFile returns the path of the user config file if no -c flag is
+// given, which is ~/.config/translate-cli/config.yaml
+func DefaultUserFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "translate-cli", "config.yaml"), nil
+}
+
+// Load reads the user config file, or the default one if file is empty, and
+// the project file. Nothing is written. A missing default file is fine, the
+// commands which need a provider check it themselves.
+func Load(file string) error {
+	explicit := file != ""
+	if !explicit {
+		var err error
+		if file, err = DefaultUserFile(); err != nil {
+			return err
+		}
+	}
+	userFile = file
+
+	viper.SetConfigFile(file)
+	viper.SetConfigType("yaml")
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+
+	if _, err := os.Stat(file); err == nil {
+		if err := viper.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config %s: %w", file, err)
+		}
+	} else if !os.IsNotExist(err) || explicit {
+		return fmt.Errorf("failed to read config %s: %w", file, err)
+	}
+
+	// the project config of the repository overrides the user config
+	if err := loadProjectConfig(); err != nil {
+		return fmt.Errorf("failed to read project config: %w", err)
+	}
+	return nil
+}
+
+// NewCmd returns the config command and its subcommands
+func NewCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Create, read and check the config",
+		// the config commands work without a valid config
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(newInitCmd())
+	configCmd.AddCommand(newGetCmd())
+	configCmd.AddCommand(newSetCmd())
+	configCmd.AddCommand(newValidateCmd())
+
+	return configCmd
+}
+
+func newInitCmd() *cobra.Command {
+	var force bool
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the user config file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Stat(userFile); err == nil && !force {
+				return fmt.Errorf("config file %s exists. use --force to overwrite it", userFile)
+			}
+
+			v := viper.New()
+			v.Set("provider", "openai")
+			v.Set("openai.api_key", "")
+			v.Set("openai.api_base", "https://api.openai.com/v1")
+			v.Set("openai.model", "gpt-4o-mini")
+			v.Set("debug", false)
+			if err := writeUserFile(v); err != nil {
+				return err
+			}
+
+			cmd.Println("created config file:", userFile)
+			cmd.Println("set the api key with `translate-cli config set openai.api_key <key>`, then run `translate-cli config validate`")
+			return nil
+		},
+	}
+	initCmd.Flags().BoolVar(&force, "force", false, "overwrite the existing config file")
+	return initCmd
+}
+
+func newGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print a setting, e.g. openai.model",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := args[0]
+			if !viper.IsSet(key) {
+				return fmt.Errorf("%s is not set", key)
+			}
+
+			value := viper.Get(key)
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				buf, err := yaml.Marshal(value)
+				if err != nil {
+					return err
+				}
+				cmd.Print(string(buf))
+			default:
+				cmd.Println(viper.GetString(key))
+			}
+			return nil
+		},
+	}
+}
+
+func newSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Write a setting to the user config file, e.g. openai.api_key",
+		Long: `Write a setting to the user config file. The value is read as YAML,
+so "20" is a number, "true" is a boolean and "[ja, zh-TW]" is a list.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, raw := args[0], args[1]
+
+			v := viper.New()
+			v.SetConfigFile(userFile)
+			v.SetConfigType("yaml")
+			if _, err := os.Stat(userFile); err == nil {
+				if err := v.ReadInConfig(); err != nil {
+					return fmt.Errorf("failed to read config %s: %w", userFile, err)
+				}
+			}
+
+			var value interface{} = raw
+			if err := yaml.Unmarshal([]byte(raw), &value); err != nil || value == nil {
+				value = raw
+			}
+			v.Set(key, value)
+			if err := writeUserFile(v); err != nil {
+				return err
+			}
+			cmd.Printf("set %s in %s\n", key, userFile)
+			return nil
+		},
+	}
+}
+
+// writeUserFile writes the settings to the user config file. A new file is
+// only readable by the user, since it keeps the API keys.
+func writeUserFile(v *viper.Viper) error {
+	if userFile == "" {
+		return fmt.Errorf("the path of the config file is unknown. use -c flag to specify it")
+	}
+	if err := os.MkdirAll(filepath.Dir(userFile), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(userFile); os.IsNotExist(err) {
+		v.SetConfigPermissions(0600)
+	}
+	return v.WriteConfigAs(userFile)
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the config file of a project, which is kept in the
+// repository. It is looked up in the working directory and its parents.
+const ProjectFileName = ".translate-cli.yaml"
+
+var (
+	// projectFile is the path of the project config file in use, if any
+	projectFile string
+
+	// projectPathKeys are the settings which are paths. In the project file
+	// they are relative to the file.
+	projectPathKeys = []string{"source", "dir", "glossary", "background"}
+
+	// projectKeys are the settings a project file may set. The provider, the
+	// API keys and the endpoints are only read from the user config, so a
+	// repository can not send the key of the user to another server.
+	projectKeys = map[string]bool{
+		"source":        true,
+		"dir":           true,
+		"glossary":      true,
+		"background":    true,
+		"batch":         true,
+		"concurrency":   true,
+		"sort_keys":     true,
+		"layout":        true,
+		"targets":       true,
+		"source_lang":   true,
+		"key_separator": true,
+		"flat_keys":     true,
+		"mask":          true,
+		"max_retries":   true,
+		"languages":     true,
+		"openai.model":  true,
+	}
+
+	// projectLanguageKeys are the settings a project file may set per language
+	projectLanguageKeys = map[string]bool{
+		"background": true,
+		"batch":      true,
+	}
+
+	// projectFlags maps the flags of the commands to the settings which are
+	// used if the flag is not given
+	projectFlags = map[string]string{
+		"source":      "source",
+		"dir":         "dir",
+		"glossary":    "glossary",
+		"background":  "background",
+		"batch":       "batch",
+		"concurrency": "concurrency",
+		"sort-keys":   "sort_keys",
+	}
+)
+
+// findProjectFile returns the nearest project file from the working
+// directory up, or "" if there is none
+func findProjectFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadProjectConfig merges the project file into the config. Its settings
+// override the user config, the flags override both.
+func loadProjectConfig() error {
+	path := findProjectFile()
+	if path == "" {
+		return nil
+	}
+	// the user config may be the same file, e.g. ~/.translate-cli.yaml
+	if used := viper.ConfigFileUsed(); used != "" {
+		if a, err := filepath.Abs(used); err == nil && a == path {
+			return nil
+		}
+	}
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	settings := make(map[string]interface{})
+	if err := yaml.Unmarshal(buf, &settings); err != nil {
+		return fmt.Errorf("parse %s failed: %w", path, err)
+	}
+
+	ignored := filterProjectSettings(settings, projectKeys, "")
+	root := filepath.Dir(path)
+	resolveProjectPaths(settings, root)
+	if languages, ok := settings["languages"].(map[string]interface{}); ok {
+		for code, v := range languages {
+			if overrides, ok := v.(map[string]interface{}); ok {
+				ignored = append(ignored, filterProjectSettings(overrides, projectLanguageKeys, "languages."+code+".")...)
+				resolveProjectPaths(overrides, root)
+			}
+		}
+	}
+	if len(ignored) != 0 {
+		sort.Strings(ignored)
+		fmt.Fprintf(os.Stderr, "%s: %s can not be set in the project config. ignore them.\n", path, strings.Join(ignored, ", "))
+	}
+
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	projectFile = path
+	return nil
+}
+
+// filterProjectSettings removes the settings which are not allowed, e.g.
+// openai.api_key, and returns their keys. A section like "openai" is kept
+// with the allowed settings in it.
+func filterProjectSettings(settings map[string]interface{}, allowed map[string]bool, prefix string) []string {
+	ignored := make([]string, 0)
+	for k, v := range settings {
+		key := strings.ToLower(k)
+		if allowed[key] {
+			continue
+		}
+		section, ok := v.(map[string]interface{})
+		if !ok {
+			delete(settings, k)
+			ignored = append(ignored, prefix+key)
+			continue
+		}
+		sectionAllowed := make(map[string]bool)
+		for name := range allowed {
+			if rest, found := strings.CutPrefix(name, key+"."); found {
+				sectionAllowed[rest] = true
+			}
+		}
+		ignored = append(ignored, filterProjectSettings(section, sectionAllowed, prefix+key+".")...)
+		if len(section) == 0 {
+			delete(settings, k)
+		}
+	}
+	return ignored
+}
+
+// resolveProjectPaths makes the relative paths of the settings relative to
+// the working directory instead of the project root
+func resolveProjectPaths(settings map[string]interface{}, root string) {
+	for _, key := range projectPathKeys {
+		p, ok := settings[key].(string)
+		if !ok || p == "" || filepath.IsAbs(p) {
+			continue
+		}
+		p = filepath.Join(root, p)
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, p); err == nil {
+				p = rel
+			}
+		}
+		settings[key] = p
+	}
+}
+
+// ApplyFlags sets the flags of the command which are not given from the
+// config, e.g. "source: locales/en.json" for -s
+func ApplyFlags(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		key, ok := projectFlags[f.Name]
+		if !ok || f.Changed || err != nil || !viper.InConfig(key) {
+			return
+		}
+		if e := cmd.Flags().Set(f.Name, viper.GetString(key)); e != nil {
+			err = fmt.Errorf("invalid %s in the config: %w", key, e)
+		}
+	})
+	return err
+}
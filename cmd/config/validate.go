@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/quailyquaily/translate-cli/cmd/parser"
+	"github.com/quailyquaily/translate-cli/internal/assistant"
+	"github.com/quailyquaily/translate-cli/internal/placeholder"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// CheckProvider reports the first missing setting of the configured provider,
+// so no request is sent without credentials.
+func CheckProvider() error {
+	name := viper.GetString("provider")
+	if name == "" {
+		return fmt.Errorf("the provider is not configured. run `translate-cli config init` to create the config file")
+	}
+	provider, ok := assistant.Providers[name]
+	if !ok {
+		return fmt.Errorf("provider %q is not supported, the supported providers are %s", name, strings.Join(assistant.ProviderNames(), ", "))
+	}
+	for _, setting := range provider.Settings {
+		key := provider.Section + "." + setting
+		if viper.GetString(key) == "" {
+			return fmt.Errorf("%s of the provider %s is not set. set it with `translate-cli config set %s <value>`", key, name, key)
+		}
+	}
+	return nil
+}
+
+func newValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config and the project config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Stat(userFile); err == nil {
+				cmd.Println("📄 config:", userFile)
+			} else {
+				cmd.Printf("📄 config: %s does not exist. run `translate-cli config init` to create it.\n", userFile)
+			}
+			if projectFile != "" {
+				cmd.Println("📄 project config:", projectFile)
+			}
+
+			problems := make([]string, 0)
+			if _, err := os.Stat(userFile); err == nil {
+				v := viper.New()
+				v.SetConfigFile(userFile)
+				v.SetConfigType("yaml")
+				if err := v.ReadInConfig(); err != nil {
+					problems = append(problems, fmt.Sprintf("failed to read config %s: %s", userFile, err))
+				}
+			}
+			if err := CheckProvider(); err != nil {
+				problems = append(problems, err.Error())
+			}
+			if sep := viper.GetString("key_separator"); viper.IsSet("key_separator") && (sep == "" || strings.Contains(sep, `\`)) {
+				problems = append(problems, fmt.Sprintf("invalid key separator %q", sep))
+			}
+			if err := parser.CheckLayout(viper.GetString("layout")); err != nil {
+				problems = append(problems, err.Error())
+			}
+			if code := viper.GetString("source_lang"); code != "" {
+				if err := parser.CheckLangCode(code); err != nil {
+					problems = append(problems, fmt.Sprintf("invalid source_lang %q: %s", code, err))
+				}
+			}
+			for _, code := range viper.GetStringSlice("targets") {
+				if err := parser.CheckLangCode(code); err != nil {
+					problems = append(problems, fmt.Sprintf("invalid target %q: %s", code, err))
+				}
+			}
+			if viper.IsSet("mask") {
+				if _, err := placeholder.NewMasker(viper.GetStringSlice("mask.rules"), viper.GetStringSlice("mask.patterns")); err != nil {
+					problems = append(problems, err.Error())
+				}
+			}
+
+			if len(problems) != 0 {
+				for _, p := range problems {
+					cmd.Println("❌", p)
+				}
+				return fmt.Errorf("the config is not valid")
+			}
+			provider := viper.GetString("provider")
+			if model := viper.GetString(assistant.Providers[provider].Section + ".model"); model != "" {
+				cmd.Printf("✅ the config is valid, provider: %s, model: %s\n", provider, model)
+			} else {
+				cmd.Printf("✅ the config is valid, provider: %s\n", provider)
+			}
+			return nil
+		},
+	}
+}
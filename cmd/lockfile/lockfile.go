@@ -0,0 +1,188 @@
+package lockfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/quailyquaily/translate-cli/cmd/parser"
+)
+
+// FileName is the name of the lock file, it is kept next to the source file,
+// or in the directory of the layout
+const FileName = ".translate-cli.lock"
+
+type (
+	// Lock records how the keys of the target files were translated.
+	// It is safe for concurrent use.
+	Lock struct {
+		path string
+		mu   sync.Mutex
+
+		// Files is keyed by the target file, relative to the lock file
+		Files map[string]map[string]*Entry `json:"files"`
+	}
+
+	Entry struct {
+		// Machine is true if the value was written by the AI and is not reviewed yet
+		Machine bool `json:"machine,omitempty"`
+		// Source is the hash of the source text the value was translated from
+		Source string `json:"source,omitempty"`
+	}
+)
+
+// Hash returns the short hash of a source text which is kept in the lock file.
+func Hash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// PathFor returns the path of the lock file for a source file. The sources
+// of all namespaces of a layout share one lock file.
+func PathFor(source *parser.LocaleFileContent) string {
+	return filepath.Join(source.Dir(), FileName)
+}
+
+// Load reads the lock file. A missing file gives an empty lock.
+func Load(path string) (*Lock, error) {
+	l := &Lock{
+		path:  path,
+		Files: make(map[string]map[string]*Entry),
+	}
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, l); err != nil {
+		return nil, err
+	}
+	if l.Files == nil {
+		l.Files = make(map[string]map[string]*Entry)
+	}
+	return l, nil
+}
+
+// Save writes the lock file with sorted keys.
+func (l *Lock) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// drop the entries which do not record anything
+	for id, entries := range l.Files {
+		for key, e := range entries {
+			if e == nil || (!e.Machine && e.Source == "") {
+				delete(entries, key)
+			}
+		}
+		if len(entries) == 0 {
+			delete(l.Files, id)
+		}
+	}
+
+	buf, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return parser.WriteFile(l.path, append(buf, '\n'))
+}
+
+// Get returns the entry of a key in the target, or nil.
+func (l *Lock) Get(target *parser.LocaleFileContent, key string) *Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.Files[l.fileID(target)][key]
+}
+
+// Set records the entry of a key in the target.
+func (l *Lock) Set(target *parser.LocaleFileContent, key string, e *Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := l.fileID(target)
+	if l.Files[id] == nil {
+		l.Files[id] = make(map[string]*Entry)
+	}
+	l.Files[id][key] = e
+}
+
+// Delete forgets the key in the target, e.g. when it is removed from the file.
+func (l *Lock) Delete(target *parser.LocaleFileContent, key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.Files[l.fileID(target)], key)
+}
+
+// SourceChanged reports whether the source text of a key in the target
+// differs from the one it was translated from. Keys without a recorded
+// source are not reported.
+func (l *Lock) SourceChanged(target *parser.LocaleFileContent, key, text string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.Files[l.fileID(target)][key]
+	return e != nil && e.Source != "" && e.Source != Hash(text)
+}
+
+// Track records the source text of a key in the target if the lock does not
+// know it yet, e.g. for translations written before the lock file existed.
+func (l *Lock) Track(target *parser.LocaleFileContent, key, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	id := l.fileID(target)
+	if l.Files[id] == nil {
+		l.Files[id] = make(map[string]*Entry)
+	}
+	e := l.Files[id][key]
+	if e == nil {
+		e = &Entry{}
+		l.Files[id][key] = e
+	}
+	if e.Source == "" {
+		e.Source = Hash(text)
+	}
+}
+
+// MachineKeys returns the keys of the target which are translated by the AI, in sorted order.
+func (l *Lock) MachineKeys(target *parser.LocaleFileContent) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := make([]string, 0)
+	for key, e := range l.Files[l.fileID(target)] {
+		if e.Machine {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fileID identifies the target in the lock file. Files which keep all
+// languages get the language code appended, e.g. "Localizable.xcstrings#ja".
+func (l *Lock) fileID(target *parser.LocaleFileContent) string {
+	id := target.Path
+	if abs, err := filepath.Abs(target.Path); err == nil {
+		if dir, err := filepath.Abs(filepath.Dir(l.path)); err == nil {
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				id = rel
+			}
+		}
+	}
+	id = filepath.ToSlash(id)
+	if target.IsMultiLang() {
+		id += "#" + target.Code
+	}
+	return id
+}
@@ -0,0 +1,135 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/translate-cli/cmd/parser"
+)
+
+// newTarget writes a JSON source and its ja target in dir, and returns the target
+func newTarget(t *testing.T, dir string) *parser.LocaleFileContent {
+	t.Helper()
+	for name, content := range map[string]string{
+		"en.json": `{"a": "A", "b": "B"}`,
+		"ja.json": `{"a": "エー"}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source, err := parser.NewSourceFromFile(filepath.Join(dir, "en.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := source.FindTargets(dir, false)
+	if len(targets) != 1 {
+		t.Fatalf("found %d targets, want 1", len(targets))
+	}
+	return targets[0]
+}
+
+func TestLockSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	target := newTarget(t, dir)
+	path := filepath.Join(dir, FileName)
+
+	lock, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Set(target, "a", &Entry{Machine: true, Source: Hash("A")})
+	lock.Set(target, "b", &Entry{})
+	lock.Set(target, "c", &Entry{Machine: true})
+	lock.Delete(target, "c")
+	if err := lock.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "files": {
+    "ja.json": {
+      "a": {
+        "machine": true,
+        "source": "` + Hash("A") + `"
+      }
+    }
+  }
+}
+`
+	if string(buf) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf, want)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := loaded.Get(target, "a"); e == nil || !e.Machine || e.Source != Hash("A") {
+		t.Errorf("got entry %+v after loading", e)
+	}
+	if keys := loaded.MachineKeys(target); !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("got machine keys %q, want [a]", keys)
+	}
+}
+
+func TestLockSourceChanged(t *testing.T) {
+	dir := t.TempDir()
+	target := newTarget(t, dir)
+	lock, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Set(target, "a", &Entry{Source: Hash("A")})
+	lock.Track(target, "b", "B")
+	// a recorded source is kept
+	lock.Track(target, "a", "A2")
+
+	tests := []struct {
+		key, text string
+		want      bool
+	}{
+		{"a", "A", false},
+		{"a", "A2", true},
+		{"b", "B", false},
+		{"b", "B!", true},
+		{"unknown", "X", false},
+	}
+	for _, tt := range tests {
+		if got := lock.SourceChanged(target, tt.key, tt.text); got != tt.want {
+			t.Errorf("SourceChanged(%q, %q) = %v, want %v", tt.key, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLoadMissingAndInvalid(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := Load(filepath.Join(dir, FileName))
+	if err != nil || lock.Files == nil {
+		t.Errorf("Load of a missing file = %+v, %v, want an empty lock", lock, err)
+	}
+
+	path := filepath.Join(dir, "broken.lock")
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load of an invalid file succeeds")
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash("A") != Hash("A") || Hash("A") == Hash("B") {
+		t.Error("Hash is not stable or not distinct")
+	}
+	if h := Hash("A"); len(h) != 16 || strings.Trim(h, "0123456789abcdef") != "" {
+		t.Errorf("Hash(%q) = %q, want 16 hex digits", "A", h)
+	}
+}
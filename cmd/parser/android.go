@@ -0,0 +1,608 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+// Android string resources, one file per language, e.g. "values-ja/strings.xml".
+//
+// Keys are the resource names. Plurals use one key per quantity, e.g. "files[one]",
+// and string arrays one key per item, e.g. "planets[0]". Resources with
+// translatable="false" are skipped. Only the values which change are rewritten,
+// the rest of the file is kept as it is.
+
+type (
+	androidFormat struct{}
+
+	androidFile struct {
+		text string
+		// resources keeps the resources in file order
+		resources []*androidResource
+		// closeOffset is the offset of </resources>
+		closeOffset int
+	}
+
+	androidResource struct {
+		// kind is one of "string", "plurals" and "string-array"
+		kind         string
+		name         string
+		translatable bool
+		items        []*androidItem
+		// closeOffset is the offset of the closing tag
+		closeOffset int
+		// start and end are the offsets of the whole element
+		start int
+		end   int
+	}
+
+	androidItem struct {
+		quantity string
+		// start and end are the offsets of the raw inner XML
+		start int
+		end   int
+		value string
+		// elemStart and elemEnd are the offsets of the whole <item> element
+		elemStart int
+		elemEnd   int
+	}
+
+	androidEdit struct {
+		start int
+		end   int
+		text  string
+	}
+)
+
+const (
+	androidDefaultIndent = "    "
+	androidEmptyFile     = "<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<resources>\n</resources>\n"
+)
+
+var (
+	// values-ja, values-zh-rTW
+	androidQualifierRe = regexp.MustCompile(`^values-([a-z]{2,3})(?:-r([A-Z]{2}|[0-9]{3}))?$`)
+	// values-b+sr+Latn
+	androidBCP47QualifierRe = regexp.MustCompile(`^values-b\+([a-zA-Z0-9+]+)$`)
+	// ja, zh-TW, es-419
+	androidLangRe = regexp.MustCompile(`^([a-z]{2,3})(?:[-_]([A-Z]{2}|[0-9]{3}))?$`)
+
+	androidEntityRe    = regexp.MustCompile(`^&(#[0-9]+|#x[0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+	androidReferenceRe = regexp.MustCompile(`^@(\w+:)?\w+/\w+$`)
+)
+
+func init() {
+	RegisterFormat(androidFormat{})
+}
+
+func (androidFormat) Name() string {
+	return "android"
+}
+
+func (androidFormat) Extensions() []string {
+	return []string{".xml"}
+}
+
+func (androidFormat) Parse(l *LocaleFileContent, buf []byte) error {
+	f, err := parseAndroid(buf)
+	if err != nil {
+		return err
+	}
+	l.formatData = f
+
+	result := structs.NewJSONMap()
+	for _, r := range f.resources {
+		if !r.translatable {
+			continue
+		}
+		for ix, item := range r.items {
+			if androidReferenceRe.MatchString(item.value) {
+				continue
+			}
+			result.SetValue(r.itemKey(ix), item.value)
+		}
+	}
+
+	l.LocaleItemsMap = result
+	return nil
+}
+
+// ExpandSource builds one item per plural quantity of the target language
+func (androidFormat) ExpandSource(source, target *LocaleFileContent) structs.JSONMap {
+	f, ok := source.formatData.(*androidFile)
+	if !ok {
+		return source.LocaleItemsMap
+	}
+
+	categories := PluralCategories(target.Code)
+	result := structs.NewJSONMap()
+	for _, r := range f.resources {
+		if !r.translatable {
+			continue
+		}
+		if r.kind != "plurals" {
+			for ix, item := range r.items {
+				if androidReferenceRe.MatchString(item.value) {
+					continue
+				}
+				result.SetValue(r.itemKey(ix), item.value)
+			}
+			continue
+		}
+		for _, cat := range categories {
+			item := r.item(cat)
+			if item == nil {
+				item = r.item("other")
+			}
+			if item != nil {
+				result.SetValue(pluralKey(r.name, cat), item.value)
+			}
+		}
+	}
+	return result
+}
+
+func (androidFormat) Marshal(l *LocaleFileContent) ([]byte, error) {
+	f, _ := l.formatData.(*androidFile)
+	if f == nil {
+		var err error
+		if f, err = parseAndroid([]byte(androidEmptyFile)); err != nil {
+			return nil, err
+		}
+		l.formatData = f
+	}
+	indent := f.indent()
+
+	edits := make([]androidEdit, 0)
+	known := make(map[string]bool)
+	for _, r := range f.resources {
+		known[r.name] = true
+		if !r.translatable {
+			continue
+		}
+		for ix, item := range r.items {
+			key := r.itemKey(ix)
+			if _, ok := l.LocaleItemsMap[key]; !ok {
+				continue
+			}
+			if v := l.LocaleItemsMap.GetString(key); v != item.value {
+				edits = append(edits, androidEdit{item.start, item.end, androidEscape(v)})
+				item.value = v
+			}
+		}
+		switch r.kind {
+		case "plurals":
+			// add the quantities the target language needs, before the
+			// quantity which follows them
+			for ix, form := range pluralForms {
+				cat := pluralFormNames[form]
+				v := l.LocaleItemsMap.GetString(pluralKey(r.name, cat))
+				if r.item(cat) != nil || v == "" {
+					continue
+				}
+				offset := lineStart(f.text, r.closeOffset)
+				for _, next := range pluralForms[ix+1:] {
+					if item := r.item(pluralFormNames[next]); item != nil {
+						offset = lineStart(f.text, item.elemStart)
+						break
+					}
+				}
+				edits = append(edits, androidEdit{offset, offset, indent + indent + androidItemXML(cat, v) + "\n"})
+			}
+		case "string-array":
+			// add the items the array of the target is short of. They are
+			// found by position, so it stops at the first one which is not
+			// translated.
+			added := ""
+			for ix := len(r.items); ; ix++ {
+				v := l.LocaleItemsMap.GetString(indexKey(r.name, ix))
+				if v == "" {
+					break
+				}
+				added += indent + indent + "<item>" + androidEscape(v) + "</item>\n"
+			}
+			if added != "" {
+				offset := lineStart(f.text, r.closeOffset)
+				edits = append(edits, androidEdit{offset, offset, added})
+			}
+		}
+	}
+
+	// add the translated resources which only exist in the source, after the
+	// resource which is before them in the source, so the order of a file
+	// which is written per batch is the order of the source
+	if l.Source != nil {
+		if src, ok := l.Source.formatData.(*androidFile); ok {
+			offset := f.insertOffset(nil)
+			for _, r := range src.resources {
+				if known[r.name] {
+					offset = f.insertOffset(f.resource(r.name))
+					continue
+				}
+				if !r.translatable {
+					continue
+				}
+				added := r.render(l.LocaleItemsMap, indent)
+				if added == "" {
+					continue
+				}
+				if offset > 0 && f.text[offset-1] != '\n' {
+					added = "\n" + added
+				}
+				edits = append(edits, androidEdit{offset, offset, added})
+			}
+		}
+	}
+
+	if err := f.apply(edits); err != nil {
+		return nil, err
+	}
+	return []byte(f.text), nil
+}
+
+// Remove deletes the resources of the keys, or the items of plurals and
+// string arrays if the resource keeps other items
+func (androidFormat) Remove(l *LocaleFileContent, keys []string) error {
+	f, _ := l.formatData.(*androidFile)
+	if f == nil {
+		return nil
+	}
+
+	remove := make(map[string]bool)
+	for _, key := range keys {
+		remove[key] = true
+	}
+
+	edits := make([]androidEdit, 0)
+	for _, r := range f.resources {
+		if !r.translatable {
+			continue
+		}
+		itemEdits := make([]androidEdit, 0)
+		for ix, item := range r.items {
+			if remove[r.itemKey(ix)] {
+				itemEdits = append(itemEdits, f.lineEdit(item.elemStart, item.elemEnd))
+			}
+		}
+		switch {
+		case len(itemEdits) == 0:
+		case len(itemEdits) == len(r.items) || r.kind == "string":
+			edits = append(edits, f.lineEdit(r.start, r.end))
+		default:
+			edits = append(edits, itemEdits...)
+		}
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+	return f.apply(edits)
+}
+
+// resource returns the resource of the name, or nil
+func (f *androidFile) resource(name string) *androidResource {
+	for _, r := range f.resources {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// insertOffset returns where a resource which follows r is inserted: the line
+// after r, or the line of the first resource if r is nil
+func (f *androidFile) insertOffset(r *androidResource) int {
+	if r == nil {
+		if len(f.resources) == 0 {
+			return lineStart(f.text, f.closeOffset)
+		}
+		return lineStart(f.text, f.resources[0].start)
+	}
+	le := r.end
+	for le < len(f.text) && (f.text[le] == ' ' || f.text[le] == '\t' || f.text[le] == '\r') {
+		le++
+	}
+	if le < len(f.text) && f.text[le] == '\n' {
+		return le + 1
+	}
+	return r.end
+}
+
+// lineEdit deletes the element, and its line if nothing else is on it
+func (f *androidFile) lineEdit(start, end int) androidEdit {
+	ls := lineStart(f.text, start)
+	le := end
+	for le < len(f.text) && (f.text[le] == ' ' || f.text[le] == '\t' || f.text[le] == '\r') {
+		le++
+	}
+	if strings.TrimSpace(f.text[ls:start]) == "" && (le == len(f.text) || f.text[le] == '\n') {
+		if le < len(f.text) {
+			le++
+		}
+		return androidEdit{ls, le, ""}
+	}
+	return androidEdit{start, end, ""}
+}
+
+// apply changes the text with the edits, and parses it again, so the offsets
+// stay valid if the content is written again
+func (f *androidFile) apply(edits []androidEdit) error {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	var buf bytes.Buffer
+	pos := 0
+	for _, e := range edits {
+		buf.WriteString(f.text[pos:e.start])
+		buf.WriteString(e.text)
+		pos = e.end
+	}
+	buf.WriteString(f.text[pos:])
+
+	updated, err := parseAndroid(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	*f = *updated
+	return nil
+}
+
+// androidLangCode converts a resource directory to a BCP 47 language code,
+// e.g. "values-zh-rTW" to "zh-TW" and "values-b+sr+Latn" to "sr-Latn".
+func androidLangCode(dir string) (string, bool) {
+	if m := androidQualifierRe.FindStringSubmatch(dir); m != nil {
+		if m[2] != "" {
+			return m[1] + "-" + m[2], true
+		}
+		return m[1], true
+	}
+	if m := androidBCP47QualifierRe.FindStringSubmatch(dir); m != nil {
+		return strings.ReplaceAll(m[1], "+", "-"), true
+	}
+	return "", false
+}
+
+// androidDir converts a BCP 47 language code to a resource directory,
+// e.g. "zh-TW" to "values-zh-rTW" and "sr-Latn" to "values-b+sr+Latn".
+func androidDir(code string) string {
+	if m := androidLangRe.FindStringSubmatch(code); m != nil {
+		if m[2] != "" {
+			return "values-" + m[1] + "-r" + m[2]
+		}
+		return "values-" + m[1]
+	}
+	return "values-b+" + strings.NewReplacer("-", "+", "_", "+").Replace(code)
+}
+
+func parseAndroid(buf []byte) (*androidFile, error) {
+	text := string(buf)
+	if strings.TrimSpace(text) == "" {
+		text = androidEmptyFile
+	}
+	f := &androidFile{text: text, closeOffset: -1}
+
+	dec := xml.NewDecoder(strings.NewReader(text))
+	depth := 0
+	var res *androidResource
+	var item *androidItem
+	// itemDepth is the depth of the element which holds the item
+	itemDepth := 0
+	for {
+		before := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1 && t.Name.Local != "resources":
+				return nil, fmt.Errorf("the root element is %s, not resources", t.Name.Local)
+			case depth == 2 && (t.Name.Local == "string" || t.Name.Local == "plurals" || t.Name.Local == "string-array"):
+				res = &androidResource{
+					kind:         t.Name.Local,
+					translatable: true,
+					start:        before,
+				}
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "name":
+						res.name = attr.Value
+					case "translatable":
+						res.translatable = attr.Value != "false"
+					}
+				}
+				if res.kind == "string" {
+					item = &androidItem{start: int(dec.InputOffset())}
+					itemDepth = depth
+				}
+			case depth == 3 && res != nil && res.kind != "string" && t.Name.Local == "item":
+				item = &androidItem{start: int(dec.InputOffset()), elemStart: before}
+				itemDepth = depth
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "quantity" {
+						item.quantity = attr.Value
+					}
+				}
+			}
+		case xml.EndElement:
+			if item != nil && depth == itemDepth {
+				item.end = before
+				item.elemEnd = int(dec.InputOffset())
+				res.items = append(res.items, item)
+				item = nil
+			}
+			switch {
+			case depth == 1:
+				f.closeOffset = before
+			case depth == 2 && res != nil:
+				res.closeOffset = before
+				res.end = int(dec.InputOffset())
+				f.resources = append(f.resources, res)
+				res = nil
+			}
+			depth--
+		}
+	}
+	if f.closeOffset < 0 {
+		return nil, fmt.Errorf("the resources element is not closed")
+	}
+
+	for _, r := range f.resources {
+		for _, item := range r.items {
+			item.value = androidUnescape(text[item.start:item.end])
+		}
+	}
+
+	return f, nil
+}
+
+// indent returns the indentation of the first resource
+func (f *androidFile) indent() string {
+	for _, r := range f.resources {
+		if len(r.items) == 0 {
+			continue
+		}
+		start := strings.LastIndex(f.text[:r.items[0].start], "<"+r.kind)
+		if start < 0 {
+			continue
+		}
+		ls := lineStart(f.text, start)
+		if ws := f.text[ls:start]; strings.TrimSpace(ws) == "" && ws != "" {
+			return ws
+		}
+	}
+	return androidDefaultIndent
+}
+
+func (r *androidResource) itemKey(ix int) string {
+	switch r.kind {
+	case "plurals":
+		return pluralKey(r.name, r.items[ix].quantity)
+	case "string-array":
+		return indexKey(r.name, ix)
+	}
+	return r.name
+}
+
+func (r *androidResource) item(quantity string) *androidItem {
+	for _, item := range r.items {
+		if item.quantity == quantity {
+			return item
+		}
+	}
+	return nil
+}
+
+// render returns the XML of a source resource with the translated values,
+// or an empty string if nothing is translated. The source text is never written.
+func (r *androidResource) render(items structs.JSONMap, indent string) string {
+	name := xmlAttrEscape(r.name)
+	switch r.kind {
+	case "string":
+		v := items.GetString(r.name)
+		if v == "" {
+			return ""
+		}
+		return indent + `<string name="` + name + `">` + androidEscape(v) + "</string>\n"
+	case "plurals":
+		inner := ""
+		for _, form := range pluralForms {
+			cat := pluralFormNames[form]
+			if v := items.GetString(pluralKey(r.name, cat)); v != "" {
+				inner += indent + indent + androidItemXML(cat, v) + "\n"
+			}
+		}
+		if inner == "" {
+			return ""
+		}
+		return indent + `<plurals name="` + name + "\">\n" + inner + indent + "</plurals>\n"
+	case "string-array":
+		// the items are found by position, so the array is only written
+		// when all of them are translated
+		inner := ""
+		for ix := range r.items {
+			v := items.GetString(r.itemKey(ix))
+			if v == "" {
+				return ""
+			}
+			inner += indent + indent + "<item>" + androidEscape(v) + "</item>\n"
+		}
+		return indent + `<string-array name="` + name + "\">\n" + inner + indent + "</string-array>\n"
+	}
+	return ""
+}
+
+func androidItemXML(quantity, value string) string {
+	return `<item quantity="` + xmlAttrEscape(quantity) + `">` + androidEscape(value) + "</item>"
+}
+
+// androidUnescape removes the escaping of quotes, so they are not sent to the model
+func androidUnescape(raw string) string {
+	if len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+		// the quoted form keeps everything as it is
+		return raw
+	}
+	return strings.NewReplacer(`\'`, `'`, `\"`, `"`).Replace(raw)
+}
+
+// androidEscape escapes quotes and stray ampersands outside of the inline tags
+func androidEscape(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return value
+	}
+
+	var sb strings.Builder
+	inTag := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case inTag:
+			if c == '>' {
+				inTag = false
+			}
+		case c == '<':
+			inTag = true
+		case c == '\\' && i+1 < len(value):
+			// keep the existing escapes, e.g. \n, \@
+			sb.WriteByte(c)
+			i++
+			c = value[i]
+		case c == '\'' || c == '"':
+			sb.WriteByte('\\')
+		case c == '&' && !androidEntityRe.MatchString(value[i:]):
+			sb.WriteString("&amp;")
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+func xmlAttrEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// lineStart returns the start of the line of offset if there is only
+// whitespace before offset on that line, otherwise offset itself
+func lineStart(text string, offset int) int {
+	ls := strings.LastIndexByte(text[:offset], '\n') + 1
+	if strings.TrimSpace(text[ls:offset]) != "" {
+		return offset
+	}
+	return ls
+}
@@ -0,0 +1,166 @@
+package parser
+
+import "testing"
+
+const androidTestSource = `<?xml version="1.0" encoding="utf-8"?>
+<resources>
+    <string name="hello">Hello</string>
+    <string-array name="planets">
+        <item>Mercury</item>
+        <item>Venus</item>
+        <item>Earth</item>
+    </string-array>
+    <plurals name="files">
+        <item quantity="one">%d file</item>
+        <item quantity="other">%d files</item>
+    </plurals>
+    <string name="bye">Bye</string>
+</resources>
+`
+
+func TestAndroidMarshal(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		target string
+		items  map[string]string
+		want   string
+	}{
+		{
+			name:   "new file in source order",
+			code:   "ja",
+			target: "",
+			items:  map[string]string{"bye": "さようなら", "hello": "こんにちは", "files[other]": "%d 個"},
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<resources>
+    <string name="hello">こんにちは</string>
+    <plurals name="files">
+        <item quantity="other">%d 個</item>
+    </plurals>
+    <string name="bye">さようなら</string>
+</resources>
+`,
+		},
+		{
+			name:   "array is not written in part",
+			code:   "ja",
+			target: "",
+			items:  map[string]string{"planets[0]": "水星", "planets[2]": "地球"},
+			want: `<?xml version="1.0" encoding="utf-8"?>
+<resources>
+</resources>
+`,
+		},
+		{
+			name: "missing items of an array",
+			code: "ja",
+			target: `<resources>
+    <string-array name="planets">
+        <item>水星</item>
+        <item>金星</item>
+    </string-array>
+</resources>
+`,
+			items: map[string]string{"planets[2]": "地球"},
+			want: `<resources>
+    <string-array name="planets">
+        <item>水星</item>
+        <item>金星</item>
+        <item>地球</item>
+    </string-array>
+</resources>
+`,
+		},
+		{
+			name: "missing quantities in order",
+			code: "ru",
+			target: `<resources>
+    <plurals name="files">
+        <item quantity="one">%d файл</item>
+        <item quantity="other">%d файлов</item>
+    </plurals>
+</resources>
+`,
+			items: map[string]string{"files[few]": "%d файла", "files[many]": "%d файлов"},
+			want: `<resources>
+    <plurals name="files">
+        <item quantity="one">%d файл</item>
+        <item quantity="few">%d файла</item>
+        <item quantity="many">%d файлов</item>
+        <item quantity="other">%d файлов</item>
+    </plurals>
+</resources>
+`,
+		},
+		{
+			name: "new resource after its predecessor",
+			code: "ja",
+			target: `<resources>
+    <!-- greetings -->
+    <string name="hello">こんにちは</string>
+    <string name="bye">さようなら</string>
+</resources>
+`,
+			items: map[string]string{"files[other]": "%d 個", "planets[0]": "水星", "planets[1]": "金星", "planets[2]": "地球"},
+			want: `<resources>
+    <!-- greetings -->
+    <string name="hello">こんにちは</string>
+    <string-array name="planets">
+        <item>水星</item>
+        <item>金星</item>
+        <item>地球</item>
+    </string-array>
+    <plurals name="files">
+        <item quantity="other">%d 個</item>
+    </plurals>
+    <string name="bye">さようなら</string>
+</resources>
+`,
+		},
+		{
+			name: "quotes and unchanged text",
+			code: "ja",
+			target: `<resources>
+    <string name="hello">こんにちは</string>
+</resources>
+`,
+			items: map[string]string{"bye": "It's"},
+			want: `<resources>
+    <string name="hello">こんにちは</string>
+    <string name="bye">It\'s</string>
+</resources>
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := parseContent(t, androidFormat{}, "en", androidTestSource, true)
+			target := parseContent(t, androidFormat{}, tt.code, tt.target, false)
+			target.Source = source
+			if got := marshalWith(t, target, tt.items); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAndroidParse(t *testing.T) {
+	source := parseContent(t, androidFormat{}, "en", androidTestSource, true)
+	want := map[string]string{
+		"hello":        "Hello",
+		"planets[0]":   "Mercury",
+		"planets[2]":   "Earth",
+		"files[one]":   "%d file",
+		"files[other]": "%d files",
+		"bye":          "Bye",
+	}
+	for k, v := range want {
+		if got := source.LocaleItemsMap.GetString(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if n := len(source.LocaleItemsMap); n != 7 {
+		t.Errorf("got %d items, want 7", n)
+	}
+}
@@ -0,0 +1,378 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+// NewSourceFromFile parses the source file of a translation. Its language is
+// SourceLang if it is set, or the language of the file name, e.g. "ja.json".
+// It is en-US if neither is known, e.g. for "messages.pot". The files which
+// keep all languages know their source language themselves.
+func NewSourceFromFile(sourceFile string) (*LocaleFileContent, error) {
+	source := &LocaleFileContent{}
+	if err := source.ParseSourceFromFile(sourceFile); err != nil {
+		return nil, err
+	}
+	if source.IsMultiLang() {
+		return source, nil
+	}
+
+	code := SourceLang
+	if code == "" {
+		code = source.Code
+	}
+	if code == "" {
+		code = DefaultSourceLang
+	}
+	lang, err := langCodeToName(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source language %q: %w", code, err)
+	}
+
+	source.Code = code
+	source.Lang = lang
+	return source, nil
+}
+
+// Dir returns the directory of the locale files of l, which is the directory
+// the Layout starts in, or the directory of the file.
+func (l *LocaleFileContent) Dir() string {
+	if _, _, root, ok := matchLayout(l.Path); ok {
+		if root == "" {
+			return "."
+		}
+		return root
+	}
+	return filepath.Dir(l.Path)
+}
+
+// IsMultiLang reports whether the file keeps all languages, e.g. Localizable.xcstrings.
+func (l *LocaleFileContent) IsMultiLang() bool {
+	_, ok := l.Format.(MultiLangFormat)
+	return ok
+}
+
+// OrphanKeys returns the keys of the target which are not in the source l
+// anymore, in sorted order. Files which keep all languages have none, their
+// keys are the keys of the source.
+func (l *LocaleFileContent) OrphanKeys(target *LocaleFileContent) []string {
+	keys := make([]string, 0)
+	if target.IsMultiLang() {
+		return keys
+	}
+	sourceItems := l.SourceItemsFor(target)
+	for key := range target.LocaleItemsMap {
+		if _, ok := sourceItems[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Remove deletes the keys from the content. The file is written on Save.
+func (l *LocaleFileContent) Remove(keys []string) error {
+	pf, ok := l.Format.(PruningFormat)
+	if !ok {
+		return fmt.Errorf("removing keys from %s is not supported", l.Path)
+	}
+	if err := pf.Remove(l, keys); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		delete(l.LocaleItemsMap, key)
+	}
+	return nil
+}
+
+var (
+	// saveLocks serializes the writes of files which share a path, e.g. the
+	// languages of a String Catalog
+	saveLocks sync.Map
+)
+
+// Save renders the content and writes it to its path atomically.
+func (l *LocaleFileContent) Save() error {
+	mu, _ := saveLocks.LoadOrStore(l.Path, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	buf, err := l.Marshal()
+	if err != nil {
+		return err
+	}
+	return WriteFile(l.Path, buf)
+}
+
+// WriteFile writes the file through a temporary file in the same directory,
+// so it is never left half written. The mode of an existing file is kept.
+func WriteFile(path string, buf []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		// a new file may need its language directory, e.g. "ja.lproj"
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// BackupSuffix is added to the name of a malformed file when it is backed up
+const BackupSuffix = ".bak"
+
+// FindTargets returns the target files of the source l. They are looked up in
+// dir, either by file name, e.g. "ja.json", or in per language directories,
+// e.g. "ja.lproj/Localizable.strings". dir is not used if all languages are in
+// the source file.
+//
+// With a Layout, they are the files of the namespace of l in the other
+// languages, e.g. "ja/common.json" for "en/common.json".
+//
+// Malformed files are skipped, so they are never overwritten. With repair, they
+// are backed up and their content is recovered as far as the format can.
+//
+// If Targets is set, only its languages are returned, and the languages
+// without a file get an empty one, which is created when it is saved.
+func (l *LocaleFileContent) FindTargets(dir string, repair bool) []*LocaleFileContent {
+	others := make([]*LocaleFileContent, 0)
+
+	if l.IsMultiLang() {
+		codes := l.Languages()
+		if len(Targets) != 0 {
+			codes = make([]string, 0, len(Targets))
+			for _, code := range Targets {
+				if !sameLang(code, l.Code) {
+					codes = append(codes, code)
+				}
+			}
+		}
+		for _, code := range codes {
+			localeContent, err := l.LanguageContent(code)
+			if err != nil {
+				fmt.Printf("language %s in %s is not supported: %s. skip this language.\n", code, l.Path, err)
+				continue
+			}
+			others = append(others, localeContent)
+		}
+		return others
+	}
+
+	if Layout != "" {
+		return l.findLayoutTargets(dir, repair)
+	}
+
+	items, _ := os.ReadDir(dir)
+	found := make([]string, 0)
+	sourceBaseFile := filepath.Base(l.Path)
+	sourceInfo, _ := os.Stat(l.Path)
+	for _, item := range items {
+		name := filepath.Base(item.Name())
+		filePath := path.Join(dir, item.Name())
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, BackupSuffix) {
+			// hidden files, e.g. the lock file, and backups
+			continue
+		}
+		if item.IsDir() {
+			// per language directories, e.g. ja.lproj/Localizable.strings
+			if !IsLangDir(name) {
+				continue
+			}
+			filePath = path.Join(dir, item.Name(), sourceBaseFile)
+			info, err := os.Stat(filePath)
+			if err != nil || os.SameFile(info, sourceInfo) {
+				continue
+			}
+			name = path.Join(item.Name(), sourceBaseFile)
+		} else if strings.EqualFold(item.Name(), sourceBaseFile) {
+			continue
+		}
+
+		ext := filepath.Ext(name)
+		// templates are never translated
+		if strings.EqualFold(ext, ".pot") {
+			continue
+		}
+
+		format := FormatByExt(ext)
+		if format == nil {
+			fmt.Printf("file %s is not a supported locale file (%s). skip this file.\n", name, strings.Join(SupportedExtensions(), ", "))
+			continue
+		}
+		if format != l.Format {
+			fmt.Printf("file %s is not a %s file. skip this file.\n", name, l.Format.Name())
+			continue
+		}
+
+		code := LangCodeFromPath(filePath)
+		if _, err := langCodeToName(code); err != nil {
+			fmt.Printf("file %s is not named by a language code: %s. skip this file.\n", name, err)
+			continue
+		}
+		if sameLang(code, l.Code) {
+			fmt.Printf("file %s is in the source language %s. skip this file.\n", name, l.Code)
+			continue
+		}
+		if !isTarget(code) {
+			continue
+		}
+		found = append(found, code)
+
+		if localeContent := l.parseTarget(filePath, repair); localeContent != nil {
+			others = append(others, localeContent)
+		}
+	}
+
+	for _, code := range Targets {
+		if sameLang(code, l.Code) || containsLang(found, code) {
+			continue
+		}
+		target, err := l.newTarget(l.targetPath(dir, code))
+		if err != nil {
+			fmt.Printf("language %s is not supported: %s. skip this language.\n", code, err)
+			continue
+		}
+		found = append(found, code)
+		others = append(others, target)
+	}
+
+	return others
+}
+
+// parseTarget parses a target file of l. A malformed file is reported and nil
+// is returned, unless it is repaired.
+func (l *LocaleFileContent) parseTarget(filePath string, repair bool) *LocaleFileContent {
+	localeContent := &LocaleFileContent{
+		Source: l,
+	}
+	if err := localeContent.ParseFromFile(filePath); err != nil {
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			fmt.Printf("parse file failed: %s. skip this file.\n", err)
+			return nil
+		}
+		if !repair {
+			fmt.Printf("parse file failed: %s. skip this file, use --repair to back it up and rewrite it.\n", err)
+			return nil
+		}
+		backup, err := localeContent.repair(perr)
+		if err != nil {
+			fmt.Printf("repair %s failed: %s. skip this file.\n", filePath, err)
+			return nil
+		}
+		fmt.Printf("%s is malformed: %s. it is backed up to %s, %d keys are recovered.\n",
+			filePath, perr.Err, backup, len(localeContent.LocaleItemsMap))
+	}
+	return localeContent
+}
+
+// IsNew reports whether the file does not exist yet
+func (l *LocaleFileContent) IsNew() bool {
+	_, err := os.Stat(l.Path)
+	return os.IsNotExist(err)
+}
+
+// newTarget returns an empty target of l at path
+func (l *LocaleFileContent) newTarget(path string) (*LocaleFileContent, error) {
+	code := LangCodeFromPath(path)
+	lang, err := langCodeToName(code)
+	if err != nil {
+		return nil, err
+	}
+
+	target := &LocaleFileContent{
+		Code:           code,
+		Lang:           lang,
+		Path:           path,
+		Namespace:      l.Namespace,
+		Format:         l.Format,
+		Source:         l,
+		LocaleItemsMap: structs.NewJSONMap(),
+	}
+	if err := l.Format.Parse(target, nil); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// targetPath returns the path of the target file of the language in dir,
+// named like the other files of the format, e.g. "ja.json",
+// "ja.lproj/Localizable.strings" or "values-ja/strings.xml".
+func (l *LocaleFileContent) targetPath(dir, code string) string {
+	base := filepath.Base(l.Path)
+	ext := filepath.Ext(base)
+	if _, ok := l.Format.(androidFormat); ok {
+		return path.Join(dir, androidDir(code), base)
+	}
+	if strings.HasSuffix(filepath.Base(filepath.Dir(l.Path)), ".lproj") {
+		return path.Join(dir, code+".lproj", base)
+	}
+	// the translations of a template are .po files
+	if strings.EqualFold(ext, ".pot") {
+		ext = ".po"
+	}
+	return path.Join(dir, code+ext)
+}
+
+// isTarget reports whether the language is one of Targets, or Targets is empty
+func isTarget(code string) bool {
+	return len(Targets) == 0 || containsLang(Targets, code)
+}
+
+func containsLang(codes []string, code string) bool {
+	for _, c := range codes {
+		if sameLang(c, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// repair backs the malformed file up and recovers its entries, if the format
+// supports it. The file starts empty otherwise.
+func (l *LocaleFileContent) repair(perr *ParseError) (string, error) {
+	buf, err := os.ReadFile(perr.Path)
+	if err != nil {
+		return "", err
+	}
+	backup := perr.Path + BackupSuffix
+	if err := os.WriteFile(backup, buf, 0644); err != nil {
+		return "", err
+	}
+
+	l.LocaleItemsMap = structs.NewJSONMap()
+	l.formatData = nil
+	if rf, ok := l.Format.(RepairingFormat); ok {
+		if err := rf.Repair(l, buf); err != nil {
+			l.LocaleItemsMap = structs.NewJSONMap()
+			l.formatData = nil
+		}
+	}
+	return backup, nil
+}
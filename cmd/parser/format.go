@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+type (
+	// Format reads a locale file into the flat key space of LocaleFileContent
+	// and writes it back. Implementations register themselves by extension.
+	Format interface {
+		// Name returns a short human readable name, e.g. "json"
+		Name() string
+		// Extensions returns the file extensions handled by the format, including the dot
+		Extensions() []string
+		// Parse fills l.LocaleItemsMap from the file content
+		Parse(l *LocaleFileContent, buf []byte) error
+		// Marshal renders l.LocaleItemsMap back to the file content
+		Marshal(l *LocaleFileContent) ([]byte, error)
+	}
+
+	// SourceFormat is implemented by formats which keep the source text apart
+	// from the translated value, e.g. the msgid of gettext.
+	SourceFormat interface {
+		Format
+		// ParseSource fills l.LocaleItemsMap with the source text
+		ParseSource(l *LocaleFileContent, buf []byte) error
+	}
+
+	// ExpandingFormat is implemented by formats whose targets need other
+	// entries than the source carries, e.g. more gettext plural forms.
+	ExpandingFormat interface {
+		Format
+		// ExpandSource returns the source items to translate for the target
+		ExpandSource(source, target *LocaleFileContent) structs.JSONMap
+	}
+
+	// MultiLangFormat is implemented by formats which keep all languages in
+	// one file, e.g. Apple String Catalogs.
+	MultiLangFormat interface {
+		Format
+		// Languages returns the codes of all languages found in the file
+		Languages(l *LocaleFileContent) []string
+		// ParseLanguage fills l.LocaleItemsMap with the entries of l.Code,
+		// using the file data shared with l.Source
+		ParseLanguage(l *LocaleFileContent) error
+	}
+
+	// RepairingFormat is implemented by formats which can recover the
+	// entries of a malformed file, e.g. JSON with trailing commas.
+	RepairingFormat interface {
+		Format
+		// Repair fills l.LocaleItemsMap with the entries it can recover from
+		// the file content, and writes the repaired content on Marshal
+		Repair(l *LocaleFileContent, buf []byte) error
+	}
+
+	// PruningFormat is implemented by formats which can delete entries from
+	// the file, e.g. the keys which are removed from the source.
+	PruningFormat interface {
+		Format
+		// Remove deletes the entries of the keys, so Marshal does not write
+		// them anymore. LocaleItemsMap is updated by the caller.
+		Remove(l *LocaleFileContent, keys []string) error
+	}
+)
+
+var (
+	formats = make(map[string]Format)
+)
+
+// RegisterFormat makes a format available for all of its extensions.
+// A later registration for the same extension replaces the earlier one.
+func RegisterFormat(f Format) {
+	for _, ext := range f.Extensions() {
+		formats[strings.ToLower(ext)] = f
+	}
+}
+
+// FormatByExt returns the format registered for the extension, or nil.
+func FormatByExt(ext string) Format {
+	return formats[strings.ToLower(ext)]
+}
+
+// FormatByPath returns the format registered for the extension of path, or nil.
+func FormatByPath(path string) Format {
+	return FormatByExt(filepath.Ext(path))
+}
+
+// SupportedExtensions returns all registered extensions in sorted order.
+func SupportedExtensions() []string {
+	exts := make([]string, 0, len(formats))
+	for ext := range formats {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
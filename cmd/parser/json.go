@@ -0,0 +1,896 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+// JSON files keep the text they were read from. Changed values are replaced in
+// place and new keys are added after the last key of their object, in the order
+// of the source file, so the untouched lines are written back as they were.
+//
+// Only strings are translated. The items of arrays use one key per item, e.g.
+// "days[0]". Numbers, booleans and null are copied from the source as they are.
+
+type (
+	jsonFormat struct{}
+
+	jsonStyle struct {
+		// indent is one level of indentation, newline is "\n" or "\r\n"
+		indent  string
+		newline string
+		colon   string
+	}
+
+	jsonFile struct {
+		jsonStyle
+		buf  []byte
+		root *jsonNode
+	}
+
+	// jsonNode is a value of the file with its position
+	jsonNode struct {
+		// kind is '{', '[', '"', or 0 for numbers, booleans and null
+		kind  byte
+		start int
+		end   int
+		// keys and keyStarts are the member names of an object and their positions
+		keys      []string
+		keyStarts []int
+		items     []*jsonNode
+		// text is the decoded value of a string
+		text string
+	}
+
+	// jsonValue is a value which is not in the file yet
+	jsonValue struct {
+		// kind is '{', '[', or 0 if raw is the JSON text of the value
+		kind  byte
+		raw   string
+		keys  []string
+		items []*jsonValue
+	}
+
+	jsonLeaf struct {
+		path string
+		node *jsonNode
+	}
+
+	jsonEdit struct {
+		start, end int
+		text       string
+	}
+
+	jsonMarshaler struct {
+		file    *jsonFile
+		items   structs.JSONMap
+		written map[string]bool
+		// the values added to the objects and arrays of the file
+		members  map[*jsonNode]*jsonValue
+		appended map[*jsonNode][]*jsonValue
+		// forms are the plural forms added after the member of the index
+		forms map[*jsonNode]map[int]*jsonValue
+	}
+)
+
+var (
+	jsonBOM = []byte("\xef\xbb\xbf")
+
+	jsonDefaultStyle = jsonStyle{indent: "  ", newline: "\n", colon: ": "}
+	// jsonCompactStyle is used for objects and arrays written on one line
+	jsonCompactStyle = jsonStyle{indent: "", newline: " ", colon: ": "}
+)
+
+func init() {
+	RegisterFormat(jsonFormat{})
+}
+
+func (jsonFormat) Name() string {
+	return "json"
+}
+
+func (jsonFormat) Extensions() []string {
+	return []string{".json"}
+}
+
+func (jsonFormat) Parse(l *LocaleFileContent, buf []byte) error {
+	result := structs.NewJSONMap()
+	l.LocaleItemsMap = result
+	if len(bytes.TrimSpace(buf)) == 0 {
+		// an empty file, e.g. a new target, is written like the source
+		return nil
+	}
+
+	f, err := parseJSON(buf)
+	if err != nil {
+		return err
+	}
+	l.formatData = f
+
+	for _, leaf := range jsonLeaves(f.root, "") {
+		if leaf.node.kind == '"' {
+			result.SetValue(leaf.path, leaf.node.text)
+		}
+	}
+	return nil
+}
+
+// ExpandSource builds the i18next plural forms the target language needs,
+// e.g. "files_few" and "files_many" for Polish
+func (jsonFormat) ExpandSource(source, target *LocaleFileContent) structs.JSONMap {
+	return expandSuffixPlurals(source.LocaleItemsMap, target.Code)
+}
+
+// Repair removes comments and trailing commas, and adds missing commas
+// between the values, e.g. after hand editing
+func (f jsonFormat) Repair(l *LocaleFileContent, buf []byte) error {
+	return f.Parse(l, jsonRepair(buf))
+}
+
+func (jsonFormat) Marshal(l *LocaleFileContent) ([]byte, error) {
+	f, _ := l.formatData.(*jsonFile)
+	if f == nil || f.root.kind != '{' {
+		f = newJSONFile(l)
+	} else if len(f.root.keys) == 0 {
+		// nothing to learn the style from, e.g. "{}"
+		f.useSourceStyle(l)
+	}
+
+	m := &jsonMarshaler{
+		file:     f,
+		items:    l.LocaleItemsMap,
+		written:  make(map[string]bool),
+		members:  make(map[*jsonNode]*jsonValue),
+		appended: make(map[*jsonNode][]*jsonValue),
+		forms:    make(map[*jsonNode]map[int]*jsonValue),
+	}
+
+	edits := make([]jsonEdit, 0)
+	for _, leaf := range jsonLeaves(f.root, "") {
+		if leaf.node.kind != '"' {
+			continue
+		}
+		m.written[leaf.path] = true
+		if _, ok := m.items[leaf.path]; ok && m.items.GetString(leaf.path) != leaf.node.text {
+			edits = append(edits, jsonEdit{leaf.node.start, leaf.node.end, jsonQuote(m.items.GetString(leaf.path))})
+		}
+	}
+
+	// the values which are missing, in the order of the source
+	if src, ok := l.sourceJSON(); ok {
+		m.merge(src, src.root, f.root, "")
+	}
+
+	// the keys which are not in the source. The source keys which are not
+	// written yet wait for the items before them, e.g. "days[1]" for "days[0]"
+	keys := make([]string, 0)
+	for key := range m.items {
+		if m.written[key] {
+			continue
+		}
+		if _, ok := l.sourceJSON(); ok {
+			if _, inSource := l.Source.LocaleItemsMap[key]; inSource {
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		m.add(key)
+	}
+
+	// the plural forms go before the members appended at the same offset
+	for node, forms := range m.forms {
+		for after, v := range forms {
+			edits = append(edits, f.insertEdit(node, after, v.keys, v.items))
+		}
+	}
+	for node, v := range m.members {
+		edits = append(edits, f.appendEdit(node, v.keys, v.items))
+	}
+	for node, items := range m.appended {
+		edits = append(edits, f.appendEdit(node, nil, items))
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	buf := f.apply(edits)
+	if l.SortKeys {
+		return jsonSortKeys(bytes.TrimPrefix(buf, jsonBOM))
+	}
+	return buf, nil
+}
+
+// Remove deletes the string values of the keys, and the objects and arrays
+// which are left empty by it
+func (jsonFormat) Remove(l *LocaleFileContent, keys []string) error {
+	f, _ := l.formatData.(*jsonFile)
+	if f == nil {
+		return nil
+	}
+
+	remove := make(map[string]bool)
+	for _, key := range keys {
+		remove[key] = true
+	}
+	edits, all := f.removeEdits(f.root, "", remove)
+	if all {
+		edits = []jsonEdit{{f.root.start, f.root.end, "{}"}}
+	}
+	if len(edits) == 0 {
+		return nil
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	// parse again, so the offsets match the new content
+	updated, err := parseJSON(f.apply(edits))
+	if err != nil {
+		return err
+	}
+	updated.jsonStyle = f.jsonStyle
+	l.formatData = updated
+	return nil
+}
+
+// removeEdits returns the edits which delete the keys below the node, and
+// whether all of its values are deleted
+func (f *jsonFile) removeEdits(n *jsonNode, prefix string, remove map[string]bool) ([]jsonEdit, bool) {
+	edits := make([]jsonEdit, 0)
+	removed := make([]bool, len(n.items))
+	count := 0
+	for ix, child := range n.items {
+		path := jsonChildPath(n, ix, prefix)
+		switch child.kind {
+		case '{', '[':
+			childEdits, all := f.removeEdits(child, path, remove)
+			if all {
+				removed[ix] = true
+				count += 1
+			} else {
+				edits = append(edits, childEdits...)
+			}
+		case '"':
+			if remove[path] {
+				removed[ix] = true
+				count += 1
+			}
+		}
+	}
+	if count == 0 {
+		return edits, false
+	}
+	if count == len(n.items) {
+		return nil, true
+	}
+
+	start := func(ix int) int {
+		if n.kind == '{' {
+			return n.keyStarts[ix]
+		}
+		return n.items[ix].start
+	}
+	last := len(n.items) - 1
+	for removed[last] {
+		last--
+	}
+	// a member before the last kept one goes with the space up to the next member,
+	// the members after it go with the comma before them
+	for ix := 0; ix < last; ix++ {
+		if removed[ix] {
+			edits = append(edits, jsonEdit{start(ix), start(ix + 1), ""})
+		}
+	}
+	if last < len(n.items)-1 {
+		edits = append(edits, jsonEdit{n.items[last].end, n.items[len(n.items)-1].end, ""})
+	}
+	return edits, false
+}
+
+// apply returns the content with the edits, which must be sorted and must not overlap
+func (f *jsonFile) apply(edits []jsonEdit) []byte {
+	var buf bytes.Buffer
+	pos := 0
+	for _, e := range edits {
+		buf.Write(f.buf[pos:e.start])
+		buf.WriteString(e.text)
+		pos = e.end
+	}
+	buf.Write(f.buf[pos:])
+	return buf.Bytes()
+}
+
+// jsonSortKeys rewrites the document with the keys in alphabetical order
+func jsonSortKeys(buf []byte) ([]byte, error) {
+	var data interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	// keep numbers as they are written
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	// encoding/json writes the keys of maps in order
+	return json.MarshalIndent(data, "", jsonDefaultStyle.indent)
+}
+
+// newJSONFile creates an empty object, with the style of the source if it is JSON
+func newJSONFile(l *LocaleFileContent) *jsonFile {
+	f := &jsonFile{
+		jsonStyle: jsonDefaultStyle,
+		buf:       []byte("{}"),
+		root:      &jsonNode{kind: '{', start: 0, end: 2},
+	}
+	f.useSourceStyle(l)
+	if src, ok := l.sourceJSON(); ok && bytes.HasSuffix(src.buf, []byte("\n")) {
+		f.buf = append(f.buf, f.newline...)
+	}
+	return f
+}
+
+// useSourceStyle takes the indentation, new lines and colons of the source file
+func (f *jsonFile) useSourceStyle(l *LocaleFileContent) {
+	if src, ok := l.sourceJSON(); ok {
+		f.jsonStyle = src.jsonStyle
+	}
+}
+
+func (l *LocaleFileContent) sourceJSON() (*jsonFile, bool) {
+	if l.Source == nil {
+		return nil, false
+	}
+	src, ok := l.Source.formatData.(*jsonFile)
+	return src, ok
+}
+
+// jsonLeaves returns the values below the object or array in file order
+func jsonLeaves(n *jsonNode, prefix string) []jsonLeaf {
+	leaves := make([]jsonLeaf, 0)
+	if n.kind != '{' && prefix == "" {
+		return leaves
+	}
+	for ix, child := range n.items {
+		path := jsonChildPath(n, ix, prefix)
+		if child.kind == '{' || child.kind == '[' {
+			leaves = append(leaves, jsonLeaves(child, path)...)
+		} else {
+			leaves = append(leaves, jsonLeaf{path, child})
+		}
+	}
+	return leaves
+}
+
+func jsonChildPath(n *jsonNode, ix int, prefix string) string {
+	if n.kind == '[' {
+		return indexKey(prefix, ix)
+	}
+	return joinKey(prefix, n.keys[ix])
+}
+
+// merge adds the values of the source which are missing in the target
+func (m *jsonMarshaler) merge(src *jsonFile, s, t *jsonNode, path string) {
+	switch {
+	case s.kind == '{' && t.kind == '{':
+		for ix, key := range s.keys {
+			p := jsonChildPath(s, ix, path)
+			if child := t.get(key); child != nil {
+				m.merge(src, s.items[ix], child, p)
+			} else if v := m.newValue(src, s.items[ix], p); v != nil {
+				m.pendingObject(t).set([]string{key}, v)
+			}
+		}
+	case s.kind == '[' && t.kind == '[':
+		for ix, item := range s.items {
+			p := jsonChildPath(s, ix, path)
+			if ix < len(t.items) {
+				m.merge(src, item, t.items[ix], p)
+				continue
+			}
+			// the items after a missing one would move, so they wait for it
+			v := m.newValue(src, item, p)
+			if v == nil {
+				return
+			}
+			m.appended[t] = append(m.appended[t], v)
+		}
+	}
+}
+
+// newValue builds the value of the target from the source value at the path,
+// or returns nil if it has nothing to write yet
+func (m *jsonMarshaler) newValue(src *jsonFile, s *jsonNode, path string) *jsonValue {
+	switch s.kind {
+	case '"':
+		if _, ok := m.items[path]; ok {
+			m.written[path] = true
+			return &jsonValue{raw: jsonQuote(m.items.GetString(path))}
+		}
+		if s.text == "" {
+			return &jsonValue{raw: `""`}
+		}
+		return nil
+	case '{':
+		v := &jsonValue{kind: '{'}
+		for ix, key := range s.keys {
+			if child := m.newValue(src, s.items[ix], jsonChildPath(s, ix, path)); child != nil {
+				v.set([]string{key}, child)
+			}
+		}
+		if len(v.keys) == 0 {
+			return nil
+		}
+		return v
+	case '[':
+		v := &jsonValue{kind: '['}
+		for ix, item := range s.items {
+			child := m.newValue(src, item, jsonChildPath(s, ix, path))
+			if child == nil {
+				break
+			}
+			v.items = append(v.items, child)
+		}
+		if len(v.items) == 0 {
+			return nil
+		}
+		return v
+	}
+	return &jsonValue{raw: string(src.buf[s.start:s.end])}
+}
+
+// add writes a key which is not in the source below the objects it names
+func (m *jsonMarshaler) add(key string) {
+	parts := newKeyParts(key)
+	node := m.file.root
+	for ix, part := range parts {
+		child := node.get(part)
+		if child == nil {
+			value := &jsonValue{raw: jsonQuote(m.items.GetString(key))}
+			if ix == len(parts)-1 && m.addForm(node, part, value) {
+				return
+			}
+			m.pendingObject(node).set(parts[ix:], value)
+			return
+		}
+		if child.kind != '{' {
+			return
+		}
+		node = child
+	}
+}
+
+// addForm adds a plural form, e.g. "files_few", after the last form of its
+// text in the object, and reports whether the object has one
+func (m *jsonMarshaler) addForm(n *jsonNode, key string, value *jsonValue) bool {
+	after := lastPluralForm(n.keys, key)
+	if after < 0 {
+		return false
+	}
+	if m.forms[n] == nil {
+		m.forms[n] = make(map[int]*jsonValue)
+	}
+	v, ok := m.forms[n][after]
+	if !ok {
+		v = &jsonValue{kind: '{'}
+		m.forms[n][after] = v
+	}
+	v.set([]string{key}, value)
+	return true
+}
+
+func (m *jsonMarshaler) pendingObject(n *jsonNode) *jsonValue {
+	v, ok := m.members[n]
+	if !ok {
+		v = &jsonValue{kind: '{'}
+		m.members[n] = v
+	}
+	return v
+}
+
+// set sets the value below the object, and creates the missing objects on the way
+func (v *jsonValue) set(parts []string, value *jsonValue) {
+	for ix, key := range v.keys {
+		if key == parts[0] {
+			if len(parts) > 1 && v.items[ix].kind == '{' {
+				v.items[ix].set(parts[1:], value)
+			}
+			return
+		}
+	}
+	if len(parts) > 1 {
+		child := &jsonValue{kind: '{'}
+		child.set(parts[1:], value)
+		value = child
+	} else if after := lastPluralForm(v.keys, parts[0]); after >= 0 {
+		// a plural form goes next to the other forms of its text
+		v.keys = append(v.keys[:after+1], append([]string{parts[0]}, v.keys[after+1:]...)...)
+		v.items = append(v.items[:after+1], append([]*jsonValue{value}, v.items[after+1:]...)...)
+		return
+	}
+	v.keys = append(v.keys, parts[0])
+	v.items = append(v.items, value)
+}
+
+// appendEdit adds the members to the object, or the items to the array if keys is nil
+func (f *jsonFile) appendEdit(n *jsonNode, keys []string, items []*jsonValue) jsonEdit {
+	if len(n.items) == 0 {
+		// the whole value is replaced, e.g. "{}"
+		v := &jsonValue{kind: n.kind, keys: keys, items: items}
+		return jsonEdit{n.start, n.end, f.render(v, f.lineIndent(n.start), f.jsonStyle)}
+	}
+	return f.insertEdit(n, len(n.items)-1, keys, items)
+}
+
+// insertEdit adds the members to the object, or the items to the array if
+// keys is nil, after the member of the index
+func (f *jsonFile) insertEdit(n *jsonNode, after int, keys []string, items []*jsonValue) jsonEdit {
+	v := &jsonValue{kind: n.kind, keys: keys, items: items}
+	first := n.items[0].start
+	if n.kind == '{' {
+		first = n.keyStarts[0]
+	}
+	last := n.items[after].end
+
+	style, indent := f.jsonStyle, f.lineIndent(first)
+	if !bytes.Contains(f.buf[n.start:first], []byte("\n")) {
+		style, indent = jsonCompactStyle, ""
+		style.colon = f.colon
+	}
+
+	var buf strings.Builder
+	for ix := range items {
+		buf.WriteString("," + style.newline + indent)
+		buf.WriteString(f.renderMember(v, ix, indent, style))
+	}
+	return jsonEdit{last, last, buf.String()}
+}
+
+func (f *jsonFile) render(v *jsonValue, indent string, style jsonStyle) string {
+	if v.kind == 0 {
+		return v.raw
+	}
+	open, close := "{", "}"
+	if v.kind == '[' {
+		open, close = "[", "]"
+	}
+	if len(v.items) == 0 {
+		return open + close
+	}
+
+	var buf strings.Builder
+	buf.WriteString(open)
+	for ix := range v.items {
+		if ix > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(style.newline + indent + style.indent)
+		buf.WriteString(f.renderMember(v, ix, indent+style.indent, style))
+	}
+	buf.WriteString(style.newline + indent + close)
+	return buf.String()
+}
+
+// renderMember renders one item of an array, or one key and value of an object
+func (f *jsonFile) renderMember(v *jsonValue, ix int, indent string, style jsonStyle) string {
+	item := f.render(v.items[ix], indent, style)
+	if v.kind == '[' {
+		return item
+	}
+	return jsonQuote(v.keys[ix]) + style.colon + item
+}
+
+// lineIndent returns the leading white space of the line at the offset
+func (f *jsonFile) lineIndent(offset int) string {
+	start := bytes.LastIndexByte(f.buf[:offset], '\n') + 1
+	end := start
+	for end < len(f.buf) && (f.buf[end] == ' ' || f.buf[end] == '\t') {
+		end++
+	}
+	return string(f.buf[start:end])
+}
+
+func (n *jsonNode) get(key string) *jsonNode {
+	// the last one wins for duplicate keys, as in encoding/json
+	for ix := len(n.keys) - 1; ix >= 0; ix-- {
+		if n.keys[ix] == key {
+			return n.items[ix]
+		}
+	}
+	return nil
+}
+
+func jsonQuote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func parseJSON(buf []byte) (*jsonFile, error) {
+	s := &jsonScanner{buf: buf}
+	if bytes.HasPrefix(buf, jsonBOM) {
+		s.pos = len(jsonBOM)
+	}
+	s.skipSpace()
+	root, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	s.skipSpace()
+	if s.pos < len(buf) {
+		return nil, s.errorf("unexpected %q after the end of the document", buf[s.pos])
+	}
+
+	f := &jsonFile{
+		jsonStyle: jsonDefaultStyle,
+		buf:       buf,
+		root:      root,
+	}
+	if bytes.Contains(buf, []byte("\r\n")) {
+		f.newline = "\r\n"
+	}
+	if root.kind == '{' && len(root.keys) > 0 {
+		first := root.keyStarts[0]
+		if indent := f.lineIndent(first); indent != "" && bytes.Contains(buf[root.start:first], []byte("\n")) {
+			f.indent = strings.TrimPrefix(indent, f.lineIndent(root.start))
+		}
+		// the text between the first key and its value
+		between := bytes.TrimLeft(buf[first:root.items[0].start], "\"")
+		if ix := bytes.LastIndexByte(between, '"'); ix >= 0 {
+			f.colon = string(between[ix+1:])
+		}
+	}
+	return f, nil
+}
+
+func jsonRepair(buf []byte) []byte {
+	out := make([]byte, 0, len(buf))
+	// lastIx is the index of the last character of the output which is not white space
+	lastIx := -1
+	last := func() byte {
+		if lastIx < 0 {
+			return 0
+		}
+		return out[lastIx]
+	}
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		switch {
+		case c == '"':
+			if l := last(); l == '"' || l == '}' || l == ']' || isJSONLiteralByte(l) {
+				// missing comma
+				out = append(out[:lastIx+1], append([]byte{','}, out[lastIx+1:]...)...)
+			}
+			end := i + 1
+			for end < len(buf) && buf[end] != '"' && buf[end] != '\n' {
+				if buf[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(buf) {
+				end = len(buf) - 1
+			}
+			out = append(out, buf[i:end+1]...)
+			lastIx = len(out) - 1
+			i = end
+			continue
+		case c == '/' && i+1 < len(buf) && buf[i+1] == '/':
+			for i+1 < len(buf) && buf[i+1] != '\n' {
+				i++
+			}
+			// drop the line if it only has the comment
+			out = bytes.TrimRight(out, " \t")
+			if len(out) > 0 && out[len(out)-1] == '\n' && i+1 < len(buf) {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < len(buf) && buf[i+1] == '*':
+			end := bytes.Index(buf[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+			continue
+		case c == '}' || c == ']':
+			if last() == ',' {
+				// trailing comma
+				out = append(out[:lastIx], out[lastIx+1:]...)
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			out = append(out, c)
+			continue
+		}
+		out = append(out, c)
+		lastIx = len(out) - 1
+	}
+	return out
+}
+
+func isJSONLiteralByte(c byte) bool {
+	return c >= '0' && c <= '9' || c == 'e' || c == 'l'
+}
+
+type jsonScanner struct {
+	buf []byte
+	pos int
+}
+
+func (s *jsonScanner) errorf(format string, args ...interface{}) error {
+	line, col := 1, 1
+	for _, c := range string(s.buf[:s.pos]) {
+		if c == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("line %d, column %d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.buf) {
+		switch s.buf[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) value() (*jsonNode, error) {
+	if s.pos >= len(s.buf) {
+		return nil, s.errorf("unexpected end of the document")
+	}
+	switch c := s.buf[s.pos]; c {
+	case '{':
+		return s.object()
+	case '[':
+		return s.array()
+	case '"':
+		return s.string()
+	default:
+		return s.literal()
+	}
+}
+
+func (s *jsonScanner) object() (*jsonNode, error) {
+	n := &jsonNode{kind: '{', start: s.pos, keys: []string{}}
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.buf) && s.buf[s.pos] == '}' {
+		s.pos++
+		n.end = s.pos
+		return n, nil
+	}
+	for {
+		if s.pos >= len(s.buf) || s.buf[s.pos] != '"' {
+			return nil, s.unexpected("a key")
+		}
+		keyStart := s.pos
+		key, err := s.string()
+		if err != nil {
+			return nil, err
+		}
+		s.skipSpace()
+		if s.pos >= len(s.buf) || s.buf[s.pos] != ':' {
+			return nil, s.unexpected("':'")
+		}
+		s.pos++
+		s.skipSpace()
+		item, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, key.text)
+		n.keyStarts = append(n.keyStarts, keyStart)
+		n.items = append(n.items, item)
+
+		s.skipSpace()
+		if s.pos >= len(s.buf) {
+			return nil, s.errorf("unexpected end of the document, expecting ',' or '}'")
+		}
+		switch s.buf[s.pos] {
+		case ',':
+			s.pos++
+			s.skipSpace()
+		case '}':
+			s.pos++
+			n.end = s.pos
+			return n, nil
+		default:
+			return nil, s.unexpected("',' or '}'")
+		}
+	}
+}
+
+func (s *jsonScanner) array() (*jsonNode, error) {
+	n := &jsonNode{kind: '[', start: s.pos}
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.buf) && s.buf[s.pos] == ']' {
+		s.pos++
+		n.end = s.pos
+		return n, nil
+	}
+	for {
+		item, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+
+		s.skipSpace()
+		if s.pos >= len(s.buf) {
+			return nil, s.errorf("unexpected end of the document, expecting ',' or ']'")
+		}
+		switch s.buf[s.pos] {
+		case ',':
+			s.pos++
+			s.skipSpace()
+		case ']':
+			s.pos++
+			n.end = s.pos
+			return n, nil
+		default:
+			return nil, s.unexpected("',' or ']'")
+		}
+	}
+}
+
+func (s *jsonScanner) string() (*jsonNode, error) {
+	n := &jsonNode{kind: '"', start: s.pos}
+	for i := s.pos + 1; i < len(s.buf); i++ {
+		switch s.buf[i] {
+		case '\\':
+			i++
+		case '"':
+			s.pos = i + 1
+			n.end = s.pos
+			if err := json.Unmarshal(s.buf[n.start:n.end], &n.text); err != nil {
+				s.pos = n.start
+				return nil, s.errorf("invalid string")
+			}
+			return n, nil
+		case '\n':
+			s.pos = i
+			return nil, s.errorf("unexpected new line in string")
+		}
+	}
+	s.pos = len(s.buf)
+	return nil, s.errorf("unexpected end of the document in string")
+}
+
+func (s *jsonScanner) literal() (*jsonNode, error) {
+	n := &jsonNode{start: s.pos}
+	for s.pos < len(s.buf) && strings.IndexByte("+-.0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", s.buf[s.pos]) >= 0 {
+		s.pos++
+	}
+	if s.pos == n.start {
+		return nil, s.unexpected("a value")
+	}
+	if raw := s.buf[n.start:s.pos]; !json.Valid(raw) {
+		s.pos = n.start
+		return nil, s.errorf("invalid value %q", raw)
+	}
+	n.end = s.pos
+	return n, nil
+}
+
+func (s *jsonScanner) unexpected(expecting string) error {
+	if s.pos >= len(s.buf) {
+		return s.errorf("unexpected end of the document, expecting %s", expecting)
+	}
+	return s.errorf("unexpected %q, expecting %s", s.buf[s.pos], expecting)
+}
@@ -0,0 +1,208 @@
+package parser
+
+import "testing"
+
+func TestJSONMarshalPluralForms(t *testing.T) {
+	source := `{
+  "title": "Files",
+  "files_one": "{{count}} file",
+  "files_other": "{{count}} files",
+  "bye": "Bye"
+}
+`
+	items := map[string]string{
+		"title":       "Файлы",
+		"files_one":   "{{count}} файл",
+		"files_few":   "{{count}} файла",
+		"files_many":  "{{count}} файлов",
+		"files_other": "{{count}} файла",
+		"bye":         "Пока",
+	}
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{
+			name:   "new file",
+			target: "",
+			want: `{
+  "title": "Файлы",
+  "files_one": "{{count}} файл",
+  "files_other": "{{count}} файла",
+  "files_few": "{{count}} файла",
+  "files_many": "{{count}} файлов",
+  "bye": "Пока"
+}
+`,
+		},
+		{
+			name: "existing forms",
+			target: `{
+  "title": "Файлы",
+  "files_one": "{{count}} файл",
+  "files_other": "{{count}} файла",
+  "bye": "Пока"
+}
+`,
+			want: `{
+  "title": "Файлы",
+  "files_one": "{{count}} файл",
+  "files_other": "{{count}} файла",
+  "files_few": "{{count}} файла",
+  "files_many": "{{count}} файлов",
+  "bye": "Пока"
+}
+`,
+		},
+		{
+			name: "last member",
+			target: `{"title": "Файлы", "bye": "Пока", "files_one": "{{count}} файл", "files_other": "{{count}} файла"}
+`,
+			want: `{"title": "Файлы", "bye": "Пока", "files_one": "{{count}} файл", "files_other": "{{count}} файла", "files_few": "{{count}} файла", "files_many": "{{count}} файлов"}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := parseContent(t, jsonFormat{}, "en", source, true)
+			target := parseContent(t, jsonFormat{}, "ru", tt.target, false)
+			target.Source = src
+			if got := marshalWith(t, target, items); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"two spaces", "{\n  \"a\": \"A\",\n  \"b\": {\n    \"c\": \"C\"\n  }\n}\n"},
+		{"tabs", "{\n\t\"a\": \"A\",\n\t\"b\": {\n\t\t\"c\": \"C\"\n\t}\n}"},
+		{"crlf", "{\r\n  \"a\": \"A\",\r\n  \"b\": \"B\"\r\n}\r\n"},
+		{"bom", "\xef\xbb\xbf{\n  \"a\": \"A\"\n}\n"},
+		{"compact", `{"a":"A","b":{"c":"C"}}`},
+		{"colon style", "{\n  \"a\" : \"A\",\n  \"b\" : \"B\"\n}\n"},
+		{"escapes", "{\n  \"a\": \"caf\\u00e9 \\\"quoted\\\" <b>\\/</b>\"\n}\n"},
+		{"unsorted keys", "{\n  \"z\": \"Z\",\n  \"a\": \"A\",\n  \"m\": \"M\"\n}\n"},
+		{"other values", "{\n  \"n\": 1.50,\n  \"t\": true,\n  \"x\": null,\n  \"list\": [\"a\", 2, \"b\"],\n  \"empty\": {}\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseContent(t, jsonFormat{}, "ja", tt.text, false)
+			if got := marshalWith(t, l, nil); got != tt.text {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestJSONRewrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		items map[string]string
+		want  string
+	}{
+		{
+			name:  "value only",
+			text:  "{\n  \"z\": \"Z\",\n  \"a\": \"A\" ,\n  \"b\": {\"c\": \"C\"}\n}\n",
+			items: map[string]string{"a": "エー", "b/c": "シー"},
+			want:  "{\n  \"z\": \"Z\",\n  \"a\": \"エー\" ,\n  \"b\": {\"c\": \"シー\"}\n}\n",
+		},
+		{
+			name:  "quotes and html are escaped like JSON",
+			text:  "{\n  \"a\": \"A\"\n}\n",
+			items: map[string]string{"a": `"<b>"` + "\n"},
+			want:  "{\n  \"a\": \"\\\"<b>\\\"\\n\"\n}\n",
+		},
+		{
+			name:  "crlf new key",
+			text:  "{\r\n  \"a\": \"A\"\r\n}\r\n",
+			items: map[string]string{"b": "B"},
+			want:  "{\r\n  \"a\": \"A\",\r\n  \"b\": \"B\"\r\n}\r\n",
+		},
+		{
+			name:  "new nested key",
+			text:  "{\n\t\"a\": \"A\"\n}\n",
+			items: map[string]string{"home/title": "T"},
+			want:  "{\n\t\"a\": \"A\",\n\t\"home\": {\n\t\t\"title\": \"T\"\n\t}\n}\n",
+		},
+		{
+			name:  "empty object",
+			text:  "{}\n",
+			items: map[string]string{"a": "A"},
+			want:  "{\n  \"a\": \"A\"\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseContent(t, jsonFormat{}, "ja", tt.text, false)
+			if got := marshalWith(t, l, tt.items); got != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONMarshalSourceOrder(t *testing.T) {
+	source := "{\n    \"z\": \"Z\",\n    \"home\": {\n        \"title\": \"Title\",\n        \"body\": \"Body\"\n    },\n    \"a\": \"A\"\n}\n"
+	items := map[string]string{"a": "エー", "home/body": "本文", "home/title": "題", "z": "ゼット"}
+	want := "{\n    \"z\": \"ゼット\",\n    \"home\": {\n        \"title\": \"題\",\n        \"body\": \"本文\"\n    },\n    \"a\": \"エー\"\n}\n"
+
+	src := parseContent(t, jsonFormat{}, "en", source, true)
+	target := parseContent(t, jsonFormat{}, "ja", "", false)
+	target.Source = src
+	if got := marshalWith(t, target, items); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	sorted := parseContent(t, jsonFormat{}, "ja", "", false)
+	sorted.Source = src
+	sorted.SortKeys = true
+	want = "{\n  \"a\": \"エー\",\n  \"home\": {\n    \"body\": \"本文\",\n    \"title\": \"題\"\n  },\n  \"z\": \"ゼット\"\n}"
+	if got := marshalWith(t, sorted, items); got != want {
+		t.Errorf("sorted got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJSONValueTypes(t *testing.T) {
+	source := "{\n  \"count\": 1.50,\n  \"enabled\": true,\n  \"none\": null,\n  \"days\": [\"Mon\", \"Tue\"],\n  \"mixed\": [\"a\", 2, {\"x\": \"X\"}]\n}\n"
+	src := parseContent(t, jsonFormat{}, "en", source, true)
+
+	wantItems := map[string]string{
+		"days[0]":    "Mon",
+		"days[1]":    "Tue",
+		"mixed[0]":   "a",
+		"mixed[2]/x": "X",
+	}
+	if len(src.LocaleItemsMap) != len(wantItems) {
+		t.Errorf("got items %v, want %v", src.LocaleItemsMap, wantItems)
+	}
+	for k, v := range wantItems {
+		if got := src.LocaleItemsMap.GetString(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	target := parseContent(t, jsonFormat{}, "ja", "", false)
+	target.Source = src
+	got := marshalWith(t, target, map[string]string{"days[0]": "月", "days[1]": "火", "mixed[0]": "エー", "mixed[2]/x": "エックス"})
+	want := "{\n  \"count\": 1.50,\n  \"enabled\": true,\n  \"none\": null,\n  \"days\": [\n    \"月\",\n    \"火\"\n  ],\n  \"mixed\": [\n    \"エー\",\n    2,\n    {\n      \"x\": \"エックス\"\n    }\n  ]\n}\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// the items after a missing one keep their position, so they wait for it
+	partial := parseContent(t, jsonFormat{}, "ja", "", false)
+	partial.Source = src
+	got = marshalWith(t, partial, map[string]string{"days[1]": "火"})
+	want = "{\n  \"count\": 1.50,\n  \"enabled\": true,\n  \"none\": null\n}\n"
+	if got != want {
+		t.Errorf("partial got:\n%s\nwant:\n%s", got, want)
+	}
+}
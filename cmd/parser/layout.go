@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The placeholders of a Layout
+const (
+	layoutLang      = "{lang}"
+	layoutNamespace = "{namespace}"
+)
+
+// CheckLayout reports whether the pattern is a valid Layout, e.g.
+// "{lang}/{namespace}.json". An empty pattern is valid.
+func CheckLayout(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if strings.Count(pattern, layoutLang) != 1 {
+		return fmt.Errorf("invalid layout %q: it needs %s once", pattern, layoutLang)
+	}
+	if strings.Count(pattern, layoutNamespace) > 1 {
+		return fmt.Errorf("invalid layout %q: %s is used more than once", pattern, layoutNamespace)
+	}
+	rest := strings.NewReplacer(layoutLang, "", layoutNamespace, "").Replace(pattern)
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("invalid layout %q: only %s and %s are known", pattern, layoutLang, layoutNamespace)
+	}
+	if filepath.IsAbs(pattern) || strings.HasPrefix(pattern, "../") || strings.Contains(pattern, "/../") {
+		return fmt.Errorf("invalid layout %q: it must be relative to the directory", pattern)
+	}
+	if FormatByPath(pattern) == nil {
+		return fmt.Errorf("invalid layout %q: %q is not a supported locale file", pattern, filepath.Ext(pattern))
+	}
+	return nil
+}
+
+// layoutRegexp matches the end of a path laid out by the pattern. The
+// language and the namespace never contain a slash, so the match is always
+// the same number of path segments.
+func layoutRegexp(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, regexp.QuoteMeta(layoutLang), `(?P<lang>[^/.]+)`, 1)
+	expr = strings.Replace(expr, regexp.QuoteMeta(layoutNamespace), `(?P<namespace>[^/]+)`, 1)
+	return regexp.MustCompile(`(?:^|/)` + expr + `$`)
+}
+
+// matchLayout returns the language and the namespace of a path laid out by
+// Layout, and the directory which the layout starts in.
+func matchLayout(path string) (code, namespace, root string, ok bool) {
+	if Layout == "" {
+		return "", "", "", false
+	}
+	re := layoutRegexp(Layout)
+	path = filepath.ToSlash(path)
+	m := re.FindStringSubmatchIndex(path)
+	if m == nil {
+		return "", "", "", false
+	}
+	for ix, name := range re.SubexpNames() {
+		if m[2*ix] < 0 {
+			continue
+		}
+		switch name {
+		case "lang":
+			code = path[m[2*ix]:m[2*ix+1]]
+		case "namespace":
+			namespace = path[m[2*ix]:m[2*ix+1]]
+		}
+	}
+	root = strings.TrimSuffix(path[:m[0]], "/")
+	if m[0] == 0 {
+		root = ""
+	}
+	return code, namespace, filepath.FromSlash(root), true
+}
+
+// layoutPath returns the path of the file of the language and the namespace in dir
+func layoutPath(dir, code, namespace string) string {
+	return filepath.Join(dir, strings.NewReplacer(layoutLang, code, layoutNamespace, namespace).Replace(Layout))
+}
+
+// walkLayout calls fn for every file in dir which is laid out by Layout.
+// Hidden files and directories, and backups are skipped.
+func walkLayout(dir string, fn func(path, code, namespace string)) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		name := d.Name()
+		if path != dir && strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || strings.HasSuffix(name, BackupSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		if code, namespace, root, ok := matchLayout(rel); ok && root == "" {
+			fn(path, code, namespace)
+		}
+		return nil
+	})
+}
+
+// NewSourcesFromLayout parses the source files of all namespaces in dir, which
+// is laid out by Layout, e.g. "en/common.json" and "en/home.json" for
+// "{lang}/{namespace}.json". The source language is SourceLang, or en-US.
+func NewSourcesFromLayout(dir string) ([]*LocaleFileContent, error) {
+	code := SourceLang
+	if code == "" {
+		code = DefaultSourceLang
+	}
+
+	paths := make([]string, 0)
+	err := walkLayout(dir, func(path, lang, namespace string) {
+		if sameLang(lang, code) {
+			paths = append(paths, path)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no source file of %s is found in %s with the layout %s. use --source-lang to set the source language", code, dir, Layout)
+	}
+
+	sources := make([]*LocaleFileContent, 0, len(paths))
+	for _, path := range paths {
+		source, err := NewSourceFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// findLayoutTargets returns the files of the namespace of l in the other
+// languages of the layout. A language which has files of other namespaces
+// only gets an empty one.
+func (l *LocaleFileContent) findLayoutTargets(dir string, repair bool) []*LocaleFileContent {
+	codes := make([]string, 0)
+	paths := make(map[string]string)
+	walkLayout(dir, func(path, code, namespace string) {
+		if _, err := langCodeToName(code); err != nil {
+			if namespace == l.Namespace {
+				fmt.Printf("file %s is not named by a language code: %s. skip this file.\n", path, err)
+			}
+			return
+		}
+		if sameLang(code, l.Code) || !isTarget(code) {
+			return
+		}
+		if !containsLang(codes, code) {
+			codes = append(codes, code)
+		}
+		if namespace == l.Namespace {
+			paths[code] = path
+		}
+	})
+	for _, code := range Targets {
+		if !sameLang(code, l.Code) && !containsLang(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+
+	others := make([]*LocaleFileContent, 0, len(codes))
+	for _, code := range codes {
+		if path, ok := paths[code]; ok {
+			if target := l.parseTarget(path, repair); target != nil {
+				others = append(others, target)
+			}
+			continue
+		}
+		target, err := l.newTarget(layoutPath(dir, code, l.Namespace))
+		if err != nil {
+			fmt.Printf("language %s is not supported: %s. skip this language.\n", code, err)
+			continue
+		}
+		others = append(others, target)
+	}
+	return others
+}
@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestCheckLayout(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ok      bool
+	}{
+		{"", true},
+		{"{lang}/{namespace}.json", true},
+		{"{namespace}.{lang}.json", true},
+		{"locales/{lang}.yaml", true},
+		{"{namespace}.json", false},
+		{"{lang}/{lang}.json", false},
+		{"{lang}/{namespace}/{namespace}.json", false},
+		{"{lang}/{ns}.json", false},
+		{"/{lang}/{namespace}.json", false},
+		{"../{lang}/{namespace}.json", false},
+		{"{lang}/{namespace}.txt", false},
+	}
+	for _, tt := range tests {
+		err := CheckLayout(tt.pattern)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckLayout(%q) = %v, want ok: %v", tt.pattern, err, tt.ok)
+		}
+	}
+}
+
+func TestMatchLayout(t *testing.T) {
+	tests := []struct {
+		layout    string
+		path      string
+		code      string
+		namespace string
+		root      string
+		ok        bool
+	}{
+		{"{lang}/{namespace}.json", "en/common.json", "en", "common", "", true},
+		{"{lang}/{namespace}.json", "zh-Hant/home.page.json", "zh-Hant", "home.page", "", true},
+		{"{lang}/{namespace}.json", "web/en/common.json", "en", "common", "web", true},
+		{"{lang}/{namespace}.json", "en.json", "", "", "", false},
+		{"{lang}/{namespace}.json", "en/common.yaml", "", "", "", false},
+		{"{namespace}.{lang}.json", "common.ja.json", "ja", "common", "", true},
+		{"{namespace}.{lang}.json", "home.page.ja.json", "ja", "home.page", "", true},
+		{"locales/{lang}.json", "locales/fr.json", "fr", "", "", true},
+		{"locales/{lang}.json", "fr.json", "", "", "", false},
+	}
+	defer func(layout string) { Layout = layout }(Layout)
+	for _, tt := range tests {
+		Layout = tt.layout
+		code, namespace, root, ok := matchLayout(filepath.FromSlash(tt.path))
+		if code != tt.code || namespace != tt.namespace || root != filepath.FromSlash(tt.root) || ok != tt.ok {
+			t.Errorf("%s: matchLayout(%q) = %q, %q, %q, %v, want %q, %q, %q, %v",
+				tt.layout, tt.path, code, namespace, root, ok, tt.code, tt.namespace, tt.root, tt.ok)
+		}
+	}
+
+	Layout = ""
+	if _, _, _, ok := matchLayout("en/common.json"); ok {
+		t.Error("matchLayout matches without a layout")
+	}
+}
+
+func TestLayoutPath(t *testing.T) {
+	defer func(layout string) { Layout = layout }(Layout)
+	tests := []struct {
+		layout string
+		want   string
+	}{
+		{"{lang}/{namespace}.json", "locales/ja/common.json"},
+		{"{namespace}.{lang}.json", "locales/common.ja.json"},
+	}
+	for _, tt := range tests {
+		Layout = tt.layout
+		if got := layoutPath("locales", "ja", "common"); got != filepath.FromSlash(tt.want) {
+			t.Errorf("%s: layoutPath = %q, want %q", tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutTargets(t *testing.T) {
+	defer func(layout, lang string, targets []string) {
+		Layout, SourceLang, Targets = layout, lang, targets
+	}(Layout, SourceLang, Targets)
+	Layout = "{lang}/{namespace}.json"
+	SourceLang = "en"
+
+	dir := t.TempDir()
+	for _, name := range []string{"en/common.json", "en/home.json", "ja/common.json", "fr/home.json", ".cache/en/x.json"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(`{"title": "Title"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		targets []string
+		want    map[string][]string
+	}{
+		{nil, map[string][]string{
+			"common": {"fr/common.json", "ja/common.json"},
+			"home":   {"fr/home.json", "ja/home.json"},
+		}},
+		{[]string{"ja", "ko"}, map[string][]string{
+			"common": {"ja/common.json", "ko/common.json"},
+			"home":   {"ja/home.json", "ko/home.json"},
+		}},
+	}
+	for _, tt := range tests {
+		Targets = tt.targets
+		sources, err := NewSourcesFromLayout(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sources) != len(tt.want) {
+			t.Fatalf("targets %v: got %d sources, want %d", tt.targets, len(sources), len(tt.want))
+		}
+		for _, source := range sources {
+			paths := make([]string, 0)
+			for _, target := range source.FindTargets(dir, false) {
+				rel, err := filepath.Rel(dir, target.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, filepath.ToSlash(rel))
+			}
+			sort.Strings(paths)
+			want := tt.want[source.Namespace]
+			if len(paths) != len(want) {
+				t.Errorf("targets %v: %s has targets %q, want %q", tt.targets, source.Namespace, paths, want)
+				continue
+			}
+			for ix := range paths {
+				if paths[ix] != want[ix] {
+					t.Errorf("targets %v: %s has targets %q, want %q", tt.targets, source.Namespace, paths, want)
+					break
+				}
+			}
+		}
+	}
+}
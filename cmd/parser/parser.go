@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/lyricat/goutils/structs"
@@ -13,13 +12,57 @@ import (
 	"golang.org/x/text/language/display"
 )
 
+var (
+	// KeySeparator joins the keys of nested objects in LocaleItemsMap, e.g. "home/title".
+	// A separator or a backslash inside a key is escaped with a backslash, e.g. "a\/b".
+	KeySeparator = "/"
+
+	// FlatKeys writes new keys as they are instead of nesting them at the separator
+	FlatKeys = false
+
+	// SourceLang is the language code of the source file. The language of the
+	// file name is used if it is empty.
+	SourceLang = ""
+
+	// Targets are the language codes to translate into. All target files in
+	// the directory are used if it is empty.
+	Targets []string
+
+	// Layout is the path pattern of the locale files, relative to the
+	// directory, e.g. "{lang}/{namespace}.json" or "{namespace}.{lang}.json".
+	// The files are named by their language, e.g. "ja.json", if it is empty.
+	Layout = ""
+)
+
+// DefaultSourceLang is the language of a source file whose name is not a language code
+const DefaultSourceLang = "en-US"
+
 type (
 	LocaleFileContent struct {
 		Code string
 		Lang string
 		Path string
+		// Namespace is the namespace of the file in the Layout, e.g. "common"
+		Namespace string
 
+		Format         Format
 		LocaleItemsMap structs.JSONMap
+
+		// SortKeys writes the keys in alphabetical order instead of keeping
+		// the order of the file. It is used by formats without a fixed layout.
+		SortKeys bool
+
+		// Source is the content this file is translated from, if any.
+		// Formats use it to build entries which do not exist in the target yet.
+		Source *LocaleFileContent
+
+		// formatData keeps format specific data for writing the file back
+		formatData interface{}
+	}
+	// ParseError is returned if the content of a locale file is malformed
+	ParseError struct {
+		Path string
+		Err  error
 	}
 	GlossaryContent struct {
 		Maps map[string]*GlossaryMapItem
@@ -27,6 +70,14 @@ type (
 	GlossaryMapItem map[string]string
 )
 
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
 func (gi *GlossaryMapItem) JSON() string {
 	if gi == nil {
 		return "{}"
@@ -92,7 +143,19 @@ func (g *GlossaryContent) GetMapByLang(lang string) *GlossaryMapItem {
 	return nil
 }
 
-func (l *LocaleFileContent) ParseFromJSONFile(path string) error {
+func (l *LocaleFileContent) ParseFromFile(path string) error {
+	return l.parseFile(path, false)
+}
+
+// ParseSourceFromFile parses a file which is used as the source of a translation.
+// Formats which implement SourceFormat read the source text instead of the
+// translated value. The file name does not have to be a language code,
+// e.g. "messages.pot".
+func (l *LocaleFileContent) ParseSourceFromFile(path string) error {
+	return l.parseFile(path, true)
+}
+
+func (l *LocaleFileContent) parseFile(path string, isSource bool) error {
 	var err error
 	if _, err = os.Stat(path); err != nil {
 		return err
@@ -100,106 +163,193 @@ func (l *LocaleFileContent) ParseFromJSONFile(path string) error {
 
 	name := filepath.Base(path) // get base name of file
 	ext := filepath.Ext(name)   // get extension
-	nameWithoutExt := name[0 : len(name)-len(ext)]
 
-	if strings.ToLower(ext) != ".json" {
-		return fmt.Errorf("file %s is not a json file", name)
+	format := FormatByExt(ext)
+	if format == nil {
+		return fmt.Errorf("file %s is not a supported locale file, the supported extensions are %s", name, strings.Join(SupportedExtensions(), ", "))
 	}
 
-	lang, err := langCodeToName(nameWithoutExt)
+	code := LangCodeFromPath(path)
+	lang, err := langCodeToName(code)
 	if err != nil {
-		return err
+		if !isSource {
+			return err
+		}
+	} else {
+		l.Code = code
+		l.Lang = lang
 	}
 
-	l.Code = nameWithoutExt
-	l.Lang = lang
 	l.Path = path
+	l.Format = format
+	if _, namespace, _, ok := matchLayout(path); ok {
+		l.Namespace = namespace
+	}
 
 	if l.LocaleItemsMap == nil {
 		l.LocaleItemsMap = structs.NewJSONMap()
 	}
 
-	// read the json file
+	// read the file
 	sourceBytes, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	// convert
-	var data map[string]interface{}
-	if err := json.Unmarshal(sourceBytes, &data); err != nil {
-		l.LocaleItemsMap = structs.NewJSONMap()
-		return nil
+	if sf, ok := format.(SourceFormat); ok && isSource {
+		err = sf.ParseSource(l, sourceBytes)
+	} else {
+		err = format.Parse(l, sourceBytes)
+	}
+	if err != nil {
+		return &ParseError{Path: path, Err: err}
 	}
-
-	result := structs.NewJSONMap()
-	flatten(data, result, "")
-
-	l.LocaleItemsMap = result
 	return nil
 }
 
-func (l *LocaleFileContent) JSON() ([]byte, error) {
-	nestedData := nestedInsertion(l.LocaleItemsMap)
-	sortedData := sortMapKeys(nestedData)
+// Languages returns the codes of the other languages kept in the same file,
+// or nil if the format keeps one language per file.
+func (l *LocaleFileContent) Languages() []string {
+	mf, ok := l.Format.(MultiLangFormat)
+	if !ok {
+		return nil
+	}
+	codes := make([]string, 0)
+	for _, code := range mf.Languages(l) {
+		if code != l.Code {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
 
-	jsonData, err := json.MarshalIndent(sortedData, "", "  ")
+// LanguageContent returns the content of another language kept in the same file.
+func (l *LocaleFileContent) LanguageContent(code string) (*LocaleFileContent, error) {
+	mf, ok := l.Format.(MultiLangFormat)
+	if !ok {
+		return nil, fmt.Errorf("%s files keep one language per file", l.Format.Name())
+	}
+
+	lang, err := langCodeToName(code)
 	if err != nil {
 		return nil, err
 	}
-	return jsonData, nil
+
+	content := &LocaleFileContent{
+		Code:       code,
+		Lang:       lang,
+		Path:       l.Path,
+		Format:     l.Format,
+		Source:     l,
+		formatData: l.formatData,
+	}
+	if err := mf.ParseLanguage(content); err != nil {
+		return nil, err
+	}
+	return content, nil
 }
 
-func flatten(input, result structs.JSONMap, currentKey string) {
-	for key, value := range input {
-		newKey := key
-		if currentKey != "" {
-			newKey = currentKey + "/" + key
-		}
-		switch child := value.(type) {
-		case map[string]interface{}:
-			flatten(child, result, newKey)
+// SourceItemsFor returns the source items the target needs, which may differ
+// from LocaleItemsMap for formats implementing ExpandingFormat.
+func (l *LocaleFileContent) SourceItemsFor(target *LocaleFileContent) structs.JSONMap {
+	if ef, ok := l.Format.(ExpandingFormat); ok {
+		return ef.ExpandSource(l, target)
+	}
+	return l.LocaleItemsMap
+}
+
+// Marshal renders the content with the format it was parsed from.
+func (l *LocaleFileContent) Marshal() ([]byte, error) {
+	if l.Format == nil {
+		return nil, fmt.Errorf("file %s has no locale format", l.Path)
+	}
+	return l.Format.Marshal(l)
+}
+
+// joinKey adds the key of a nested object to the path
+func joinKey(prefix, key string) string {
+	key = escapeKey(key)
+	if prefix == "" {
+		return key
+	}
+	return prefix + KeySeparator + key
+}
+
+func escapeKey(key string) string {
+	key = strings.ReplaceAll(key, `\`, `\\`)
+	return strings.ReplaceAll(key, KeySeparator, `\`+KeySeparator)
+}
+
+// splitKey splits the path into the keys of the nested objects
+func splitKey(path string) []string {
+	parts := make([]string, 0)
+	var part strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			part.WriteByte(path[i])
+		case strings.HasPrefix(path[i:], KeySeparator):
+			parts = append(parts, part.String())
+			part.Reset()
+			i += len(KeySeparator) - 1
 		default:
-			result.SetValue(newKey, fmt.Sprint(value))
+			part.WriteByte(path[i])
 		}
 	}
+	return append(parts, part.String())
 }
 
-func nestedInsertion(input structs.JSONMap) map[string]interface{} {
-	data := make(map[string]interface{})
-	for key, value := range input {
-		parts := strings.Split(key, "/")
-		currentMap := data
-		for i, part := range parts {
-			if i == len(parts)-1 {
-				currentMap[part] = value
-			} else {
-				if _, exist := currentMap[part]; !exist {
-					currentMap[part] = make(map[string]interface{})
-				}
-				currentMap = currentMap[part].(map[string]interface{})
-			}
-		}
+// newKeyParts returns the keys of the objects to create for a new item,
+// which is the item key alone if FlatKeys is set
+func newKeyParts(path string) []string {
+	parts := splitKey(path)
+	if FlatKeys {
+		return []string{strings.Join(parts, KeySeparator)}
 	}
-	return data
+	return parts
 }
 
-func sortMapKeys(data interface{}) interface{} {
-	switch data := data.(type) {
-	case map[string]interface{}:
-		keys := make([]string, 0, len(data))
-		for key := range data {
-			keys = append(keys, key)
-		}
-		sort.Strings(keys)
-		result := make(map[string]interface{}, len(data))
-		for _, key := range keys {
-			result[key] = sortMapKeys(data[key])
-		}
-		return result
-	default:
-		return data
+// LangCodeFromPath returns the language code of a locale file. It is taken
+// from the Layout if the path matches it, from the directory for per language
+// directories, e.g. "ja.lproj/Localizable.strings" or "values-ja/strings.xml",
+// and from the file name otherwise, e.g. "ja.json".
+func LangCodeFromPath(path string) string {
+	if code, _, _, ok := matchLayout(path); ok {
+		return code
+	}
+	if code, ok := langCodeFromDir(filepath.Base(filepath.Dir(path))); ok {
+		return code
 	}
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// IsLangDir reports whether name is a per language directory,
+// e.g. "ja.lproj" or "values-zh-rTW".
+func IsLangDir(name string) bool {
+	code, ok := langCodeFromDir(name)
+	if !ok {
+		return false
+	}
+	_, err := language.Parse(code)
+	return err == nil
+}
+
+func langCodeFromDir(name string) (string, bool) {
+	if strings.HasSuffix(name, ".lproj") {
+		return strings.TrimSuffix(name, ".lproj"), true
+	}
+	if code, ok := androidLangCode(name); ok {
+		return code, true
+	}
+	return "", false
+}
+
+// CheckLangCode reports whether the code is a known language, e.g. "zh-TW"
+func CheckLangCode(code string) error {
+	_, err := langCodeToName(code)
+	return err
 }
 
 func langCodeToName(code string) (string, error) {
@@ -207,5 +357,28 @@ func langCodeToName(code string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return display.Self.Name(tag), nil
+	name := display.Self.Name(tag)
+	if name == "" {
+		return "", fmt.Errorf("unknown language %q", code)
+	}
+	return name, nil
+}
+
+// sameLang reports whether the codes name the same language, e.g. "pt_BR" and "pt-BR"
+func sameLang(a, b string) bool {
+	ta, err := language.Parse(a)
+	if err != nil {
+		return false
+	}
+	tb, err := language.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ta == tb
+}
+
+// isLangCode reports whether s is a valid language code
+func isLangCode(s string) bool {
+	_, err := language.Parse(s)
+	return err == nil
 }
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+// parseContent parses the text as a file of the language in the format
+func parseContent(t *testing.T, format Format, code, text string, isSource bool) *LocaleFileContent {
+	t.Helper()
+	l := &LocaleFileContent{
+		Code:           code,
+		Format:         format,
+		LocaleItemsMap: structs.NewJSONMap(),
+	}
+	var err error
+	if sf, ok := format.(SourceFormat); ok && isSource {
+		err = sf.ParseSource(l, []byte(text))
+	} else {
+		err = format.Parse(l, []byte(text))
+	}
+	if err != nil {
+		t.Fatalf("parse %s failed: %s", code, err)
+	}
+	return l
+}
+
+// marshalWith sets the items of the target and renders it
+func marshalWith(t *testing.T, target *LocaleFileContent, items map[string]string) string {
+	t.Helper()
+	for k, v := range items {
+		target.LocaleItemsMap.SetValue(k, v)
+	}
+	buf, err := target.Marshal()
+	if err != nil {
+		t.Fatalf("marshal %s failed: %s", target.Code, err)
+	}
+	return string(buf)
+}
+
+// replaceOnce replaces the first old in s, and panics if there is none
+func replaceOnce(s, old, new string) string {
+	ix := strings.Index(s, old)
+	if ix < 0 {
+		panic("replaceOnce: " + old + " is not found")
+	}
+	return s[:ix] + new + s[ix+len(old):]
+}
+
+func TestKeySeparator(t *testing.T) {
+	defer func(sep string, flat bool) { KeySeparator, FlatKeys = sep, flat }(KeySeparator, FlatKeys)
+	tests := []struct {
+		sep   string
+		keys  []string
+		path  string
+		flat  bool
+		parts []string
+	}{
+		{"/", []string{"home", "title"}, "home/title", false, []string{"home", "title"}},
+		{"/", []string{"a/b", "c"}, `a\/b/c`, false, []string{"a/b", "c"}},
+		{"/", []string{`a\b`, "c"}, `a\\b/c`, false, []string{`a\b`, "c"}},
+		{".", []string{"home", "v1.0"}, `home.v1\.0`, false, []string{"home", "v1.0"}},
+		{"::", []string{"a", "b:c"}, "a::b:c", false, []string{"a", "b:c"}},
+		{".", []string{"home", "title"}, "home.title", true, []string{"home.title"}},
+	}
+	for _, tt := range tests {
+		KeySeparator, FlatKeys = tt.sep, tt.flat
+		path := ""
+		for _, key := range tt.keys {
+			path = joinKey(path, key)
+		}
+		if path != tt.path {
+			t.Errorf("%q: joinKey(%q) = %q, want %q", tt.sep, tt.keys, path, tt.path)
+		}
+		if got := splitKey(path); !reflect.DeepEqual(got, tt.keys) {
+			t.Errorf("%q: splitKey(%q) = %q, want %q", tt.sep, path, got, tt.keys)
+		}
+		if got := newKeyParts(path); !reflect.DeepEqual(got, tt.parts) {
+			t.Errorf("%q: newKeyParts(%q) = %q, want %q", tt.sep, path, got, tt.parts)
+		}
+	}
+}
+
+func TestSameLang(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"pt_BR", "pt-BR", true},
+		{"zh-Hant", "zh-hant", true},
+		{"en", "en-US", false},
+		{"ja", "ko", false},
+		{"ja", "not a code!", false},
+	}
+	for _, tt := range tests {
+		if got := sameLang(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameLang(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLangCodeFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"locales/ja.json", "ja"},
+		{"ios/zh-Hant.lproj/Localizable.strings", "zh-Hant"},
+		{"res/values-zh-rTW/strings.xml", "zh-TW"},
+		{"po/pt_BR.po", "pt_BR"},
+	}
+	for _, tt := range tests {
+		if got := LangCodeFromPath(tt.path); got != tt.want {
+			t.Errorf("LangCodeFromPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/lyricat/goutils/structs"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+var (
+	indexKeyRe = regexp.MustCompile(`^(.*)\[([0-9]+)\]$`)
+	// files[one] of Android, stringsdict and String Catalogs
+	pluralKeyRe = regexp.MustCompile(`^(.*)\[(zero|one|two|few|many|other)\]$`)
+	// files_one of i18next
+	suffixPluralKeyRe = regexp.MustCompile(`^(.+)_(zero|one|two|few|many|other)$`)
+
+	// pluralForms lists the CLDR plural categories in their usual order
+	pluralForms = []plural.Form{plural.Zero, plural.One, plural.Two, plural.Few, plural.Many, plural.Other}
+
+	pluralFormNames = map[plural.Form]string{
+		plural.Zero:  "zero",
+		plural.One:   "one",
+		plural.Two:   "two",
+		plural.Few:   "few",
+		plural.Many:  "many",
+		plural.Other: "other",
+	}
+)
+
+// PluralCategories returns the CLDR cardinal plural categories a language needs,
+// e.g. ["one", "other"] for English and ["one", "few", "many", "other"] for Polish.
+func PluralCategories(code string) []string {
+	tag, err := language.Parse(code)
+	if err != nil {
+		return []string{"one", "other"}
+	}
+
+	found := map[plural.Form]bool{plural.Other: true}
+	for i := 0; i <= 1000; i++ {
+		found[plural.Cardinal.MatchPlural(tag, i, 0, 0, 0, 0)] = true
+	}
+	// some categories only match decimals, e.g. 0.5 and 1.5
+	for i := 0; i <= 2; i++ {
+		for f := 1; f <= 9; f++ {
+			found[plural.Cardinal.MatchPlural(tag, i, 1, 1, f, f)] = true
+		}
+	}
+
+	categories := make([]string, 0, len(found))
+	for _, form := range pluralForms {
+		if found[form] {
+			categories = append(categories, pluralFormNames[form])
+		}
+	}
+	return categories
+}
+
+// PluralBase returns the key of the text a plural form belongs to, e.g.
+// "files" for "files[one]" and "files_one". The forms of one text are
+// translated together.
+func PluralBase(key string) (string, bool) {
+	if m := pluralKeyRe.FindStringSubmatch(key); m != nil {
+		return m[1], true
+	}
+	if m := suffixPluralKeyRe.FindStringSubmatch(key); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// KeyGroup returns the key of the group a key belongs to: the text of a
+// plural form, or the array of an item, e.g. "planets" for "planets[0]".
+// The keys of one group are translated in one batch, so a file which is
+// saved per batch never has half of a plural or an array.
+func KeyGroup(key string) (string, bool) {
+	if base, ok := PluralBase(key); ok {
+		return base, true
+	}
+	if base, _, ok := splitIndexKey(key); ok {
+		return base, true
+	}
+	return "", false
+}
+
+// expandSuffixPlurals builds one item per plural category of the language for
+// the i18next plural groups of the items, e.g. "files_one" and "files_other".
+// A group needs "_other" and one more form, so a single key like
+// "option_other" stays as it is. A form the items do not have gets the text
+// of "_other".
+func expandSuffixPlurals(items structs.JSONMap, code string) structs.JSONMap {
+	groups := make(map[string]map[string]bool)
+	for key := range items {
+		if m := suffixPluralKeyRe.FindStringSubmatch(key); m != nil {
+			if groups[m[1]] == nil {
+				groups[m[1]] = make(map[string]bool)
+			}
+			groups[m[1]][m[2]] = true
+		}
+	}
+	for base, forms := range groups {
+		if !forms["other"] || len(forms) < 2 {
+			delete(groups, base)
+		}
+	}
+
+	result := structs.NewJSONMap()
+	for key, value := range items {
+		if m := suffixPluralKeyRe.FindStringSubmatch(key); m != nil && groups[m[1]] != nil {
+			continue
+		}
+		result[key] = value
+	}
+	categories := PluralCategories(code)
+	for base, forms := range groups {
+		for _, cat := range categories {
+			from := base + "_" + cat
+			if !forms[cat] {
+				from = base + "_other"
+			}
+			result.SetValue(base+"_"+cat, items[from])
+		}
+	}
+	return result
+}
+
+// lastPluralForm returns the index of the last key which is a plural form of
+// the same text as key, or -1
+func lastPluralForm(keys []string, key string) int {
+	m := suffixPluralKeyRe.FindStringSubmatch(key)
+	if m == nil {
+		return -1
+	}
+	last := -1
+	for ix, k := range keys {
+		if km := suffixPluralKeyRe.FindStringSubmatch(k); km != nil && km[1] == m[1] {
+			last = ix
+		}
+	}
+	return last
+}
+
+func isPluralCategory(s string) bool {
+	for _, name := range pluralFormNames {
+		if name == s {
+			return true
+		}
+	}
+	return false
+}
+
+// pluralKey returns the key of one plural form, e.g. "%d files[one]"
+func pluralKey(key, category string) string {
+	return key + "[" + category + "]"
+}
+
+// indexKey returns the key of one array item, e.g. "planets[0]"
+func indexKey(key string, ix int) string {
+	return pluralKey(key, strconv.Itoa(ix))
+}
+
+// splitIndexKey splits the key of an array item into the array key and the index
+func splitIndexKey(key string) (string, int, bool) {
+	m := indexKeyRe.FindStringSubmatch(key)
+	if m == nil {
+		return "", 0, false
+	}
+	ix, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return m[1], ix, true
+}
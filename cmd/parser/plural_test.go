@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+func TestPluralCategories(t *testing.T) {
+	tests := []struct {
+		code string
+		want []string
+	}{
+		{"en", []string{"one", "other"}},
+		{"ja", []string{"other"}},
+		{"ru", []string{"one", "few", "many", "other"}},
+		{"pl", []string{"one", "few", "many", "other"}},
+		{"ar", []string{"zero", "one", "two", "few", "many", "other"}},
+		{"not a code!", []string{"one", "other"}},
+	}
+	for _, tt := range tests {
+		if got := PluralCategories(tt.code); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PluralCategories(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestKeyGroup(t *testing.T) {
+	tests := []struct {
+		key     string
+		group   string
+		plural  bool
+		grouped bool
+	}{
+		{"files_one", "files", true, true},
+		{"files[few]", "files", true, true},
+		{"%d files[1]", "%d files", false, true},
+		{"planets[0]", "planets", false, true},
+		{"option_value", "", false, false},
+		{"_other", "", false, false},
+		{"title", "", false, false},
+	}
+	for _, tt := range tests {
+		base, ok := PluralBase(tt.key)
+		if ok != tt.plural || (ok && base != tt.group) {
+			t.Errorf("PluralBase(%q) = %q, %v, want plural: %v", tt.key, base, ok, tt.plural)
+		}
+		group, ok := KeyGroup(tt.key)
+		if group != tt.group || ok != tt.grouped {
+			t.Errorf("KeyGroup(%q) = %q, %v, want %q, %v", tt.key, group, ok, tt.group, tt.grouped)
+		}
+	}
+}
+
+func TestExpandSuffixPlurals(t *testing.T) {
+	items := structs.JSONMap{
+		"files_one":    "{{count}} file",
+		"files_other":  "{{count}} files",
+		"option_other": "Other",
+		"title":        "Title",
+	}
+	tests := []struct {
+		code string
+		want structs.JSONMap
+	}{
+		{"ja", structs.JSONMap{
+			"files_other":  "{{count}} files",
+			"option_other": "Other",
+			"title":        "Title",
+		}},
+		{"ru", structs.JSONMap{
+			"files_one":    "{{count}} file",
+			"files_few":    "{{count}} files",
+			"files_many":   "{{count}} files",
+			"files_other":  "{{count}} files",
+			"option_other": "Other",
+			"title":        "Title",
+		}},
+	}
+	for _, tt := range tests {
+		if got := expandSuffixPlurals(items, tt.code); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandSuffixPlurals(%s) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLastPluralForm(t *testing.T) {
+	keys := []string{"files_one", "title", "files_other", "days_one"}
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"files_few", 2},
+		{"days_other", 3},
+		{"weeks_one", -1},
+		{"title", -1},
+	}
+	for _, tt := range tests {
+		if got := lastPluralForm(keys, tt.key); got != tt.want {
+			t.Errorf("lastPluralForm(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSplitIndexKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		base string
+		ix   int
+		ok   bool
+	}{
+		{indexKey("planets", 3), "planets", 3, true},
+		{"a[b][10]", "a[b]", 10, true},
+		{pluralKey("files", "one"), "", 0, false},
+		{"planets", "", 0, false},
+	}
+	for _, tt := range tests {
+		base, ix, ok := splitIndexKey(tt.key)
+		if base != tt.base || ix != tt.ix || ok != tt.ok {
+			t.Errorf("splitIndexKey(%q) = %q, %d, %v, want %q, %d, %v", tt.key, base, ix, ok, tt.base, tt.ix, tt.ok)
+		}
+	}
+}
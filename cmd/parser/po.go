@@ -0,0 +1,591 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lyricat/goutils/structs"
+	"golang.org/x/text/language"
+)
+
+// gettext PO/POT files.
+//
+// Keys are the msgid, prefixed with "<msgctxt>\x04" if the entry has a context,
+// the same way gettext looks up its catalogs. Plural entries use one key per
+// form, e.g. "%d file[0]", "%d file[1]".
+
+type (
+	poFormat struct{}
+
+	poFile struct {
+		header  *poEntry
+		entries []*poEntry
+	}
+
+	poEntry struct {
+		// comments keeps all comment lines verbatim, e.g. "# note", "#: main.c:12"
+		comments []string
+
+		hasContext bool
+		context    string
+		hasID      bool
+		id         string
+		hasPlural  bool
+		idPlural   string
+		str        string
+		strPlural  []string
+
+		// raw keeps the original lines, they are written back if the entry is untouched
+		raw      []string
+		modified bool
+	}
+)
+
+const (
+	poContextSeparator  = "\x04"
+	poDefaultPluralForm = "nplurals=2; plural=(n != 1);"
+)
+
+var (
+	poNPluralsRe = regexp.MustCompile(`nplurals\s*=\s*(\d+)`)
+
+	// poPluralForms is the Plural-Forms header used when a file does not have one
+	poPluralForms = map[string]string{
+		"ja":    "nplurals=1; plural=0;",
+		"zh":    "nplurals=1; plural=0;",
+		"ko":    "nplurals=1; plural=0;",
+		"vi":    "nplurals=1; plural=0;",
+		"th":    "nplurals=1; plural=0;",
+		"id":    "nplurals=1; plural=0;",
+		"ms":    "nplurals=1; plural=0;",
+		"fr":    "nplurals=2; plural=(n > 1);",
+		"pt-BR": "nplurals=2; plural=(n > 1);",
+		"tr":    "nplurals=2; plural=(n > 1);",
+		"ru":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+		"uk":    "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+		"pl":    "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+		"cs":    "nplurals=3; plural=(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2;",
+		"sk":    "nplurals=3; plural=(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2;",
+		"ar":    "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5);",
+	}
+)
+
+func init() {
+	RegisterFormat(poFormat{})
+}
+
+func (poFormat) Name() string {
+	return "po"
+}
+
+func (poFormat) Extensions() []string {
+	return []string{".po", ".pot"}
+}
+
+func (poFormat) Parse(l *LocaleFileContent, buf []byte) error {
+	f, err := parsePO(buf)
+	if err != nil {
+		return err
+	}
+	l.formatData = f
+
+	nplurals := f.nplurals(l.Code)
+	result := structs.NewJSONMap()
+	for _, e := range f.entries {
+		if !e.hasID {
+			continue
+		}
+		key := e.key()
+		if !e.hasPlural {
+			result.SetValue(key, e.str)
+			continue
+		}
+		for i := 0; i < nplurals && i < len(e.strPlural); i++ {
+			result.SetValue(poPluralKey(key, i), e.strPlural[i])
+		}
+	}
+
+	l.LocaleItemsMap = result
+	return nil
+}
+
+// ParseSource reads the msgid of each entry, so a POT file can be used as the source
+func (poFormat) ParseSource(l *LocaleFileContent, buf []byte) error {
+	f, err := parsePO(buf)
+	if err != nil {
+		return err
+	}
+	l.formatData = f
+
+	result := structs.NewJSONMap()
+	for _, e := range f.entries {
+		if !e.hasID {
+			continue
+		}
+		key := e.key()
+		if !e.hasPlural {
+			result.SetValue(key, e.id)
+			continue
+		}
+		result.SetValue(poPluralKey(key, 0), e.id)
+		result.SetValue(poPluralKey(key, 1), e.idPlural)
+	}
+
+	l.LocaleItemsMap = result
+	return nil
+}
+
+// ExpandSource builds one item per plural form of the target language
+func (poFormat) ExpandSource(source, target *LocaleFileContent) structs.JSONMap {
+	src, ok := source.formatData.(*poFile)
+	if !ok {
+		return source.LocaleItemsMap
+	}
+
+	nplurals := poNPlurals(poPluralFormsFor(target.Code))
+	if f, ok := target.formatData.(*poFile); ok {
+		nplurals = f.nplurals(target.Code)
+	}
+
+	result := structs.NewJSONMap()
+	for _, e := range src.entries {
+		if !e.hasID {
+			continue
+		}
+		key := e.key()
+		if !e.hasPlural {
+			result.SetValue(key, e.id)
+			continue
+		}
+		for i := 0; i < nplurals; i++ {
+			// the singular only fits the first form of languages with more than one form
+			text := e.idPlural
+			if i == 0 && nplurals > 1 {
+				text = e.id
+			}
+			result.SetValue(poPluralKey(key, i), text)
+		}
+	}
+	return result
+}
+
+func (poFormat) Marshal(l *LocaleFileContent) ([]byte, error) {
+	f, _ := l.formatData.(*poFile)
+	if f == nil {
+		f = &poFile{}
+		l.formatData = f
+	}
+
+	if f.header == nil {
+		f.header = newPOHeader(l.Code)
+	} else if f.headerField("Plural-Forms") == "" {
+		f.header.str += "Plural-Forms: " + poPluralFormsFor(l.Code) + "\n"
+		f.header.modified = true
+	}
+	nplurals := f.nplurals(l.Code)
+
+	known := make(map[string]bool)
+	for _, e := range f.entries {
+		if !e.hasID {
+			continue
+		}
+		known[e.key()] = true
+		e.update(l.LocaleItemsMap, nplurals)
+	}
+
+	// add the entries which only exist in the source
+	if l.Source != nil {
+		if src, ok := l.Source.formatData.(*poFile); ok {
+			added := make([]*poEntry, 0)
+			for _, se := range src.entries {
+				if !se.hasID || known[se.key()] {
+					continue
+				}
+				e := &poEntry{
+					comments:   se.comments,
+					hasContext: se.hasContext,
+					context:    se.context,
+					hasID:      true,
+					id:         se.id,
+					hasPlural:  se.hasPlural,
+					idPlural:   se.idPlural,
+					modified:   true,
+				}
+				e.update(l.LocaleItemsMap, nplurals)
+				added = append(added, e)
+			}
+
+			// keep the obsolete entries at the end of the file
+			pos := len(f.entries)
+			for pos > 0 && !f.entries[pos-1].hasID {
+				pos--
+			}
+			entries := make([]*poEntry, 0, len(f.entries)+len(added))
+			entries = append(entries, f.entries[:pos]...)
+			entries = append(entries, added...)
+			entries = append(entries, f.entries[pos:]...)
+			f.entries = entries
+		}
+	}
+
+	var buf bytes.Buffer
+	f.header.write(&buf)
+	for _, e := range f.entries {
+		buf.WriteString("\n")
+		e.write(&buf)
+	}
+	return buf.Bytes(), nil
+}
+
+// Remove deletes the entries of the keys. An entry with plural forms is
+// deleted if its first form is removed.
+func (poFormat) Remove(l *LocaleFileContent, keys []string) error {
+	f, _ := l.formatData.(*poFile)
+	if f == nil {
+		return nil
+	}
+
+	remove := make(map[string]bool)
+	for _, key := range keys {
+		remove[key] = true
+	}
+
+	entries := make([]*poEntry, 0, len(f.entries))
+	for _, e := range f.entries {
+		if e.hasID && (remove[e.key()] || (e.hasPlural && remove[poPluralKey(e.key(), 0)])) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	f.entries = entries
+	return nil
+}
+
+func parsePO(buf []byte) (*poFile, error) {
+	f := &poFile{}
+
+	var cur *poEntry
+	// last points to the string which continuation lines are appended to
+	var last *string
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if cur.hasID && cur.id == "" && !cur.hasContext && f.header == nil {
+			f.header = cur
+		} else {
+			f.entries = append(f.entries, cur)
+		}
+		cur = nil
+		last = nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(buf), "\r\n", "\n"), "\n")
+	for ix, line := range lines {
+		lineNo := ix + 1
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			flush()
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, `"`) {
+			if last == nil {
+				return nil, fmt.Errorf("line %d: unexpected string", lineNo)
+			}
+			s, err := poUnquote(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			*last += s
+			cur.raw = append(cur.raw, line)
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") {
+			// a comment after the msgid starts the next entry
+			if cur != nil && cur.hasID {
+				flush()
+			}
+			if cur == nil {
+				cur = &poEntry{}
+			}
+			cur.comments = append(cur.comments, line)
+			cur.raw = append(cur.raw, line)
+			last = nil
+			continue
+		}
+
+		keyword, rest, _ := strings.Cut(trimmed, " ")
+		s, err := poUnquote(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		if keyword == "msgctxt" || keyword == "msgid" {
+			if cur != nil && cur.hasID {
+				flush()
+			}
+			if cur == nil {
+				cur = &poEntry{}
+			}
+		} else if cur == nil || !cur.hasID {
+			return nil, fmt.Errorf("line %d: %s without msgid", lineNo, keyword)
+		}
+		cur.raw = append(cur.raw, line)
+
+		switch {
+		case keyword == "msgctxt":
+			cur.hasContext = true
+			cur.context = s
+			last = &cur.context
+		case keyword == "msgid":
+			cur.hasID = true
+			cur.id = s
+			last = &cur.id
+		case keyword == "msgid_plural":
+			cur.hasPlural = true
+			cur.idPlural = s
+			last = &cur.idPlural
+		case keyword == "msgstr":
+			cur.str = s
+			last = &cur.str
+		case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+			n, err := strconv.Atoi(keyword[len("msgstr[") : len(keyword)-1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("line %d: invalid plural index %s", lineNo, keyword)
+			}
+			for len(cur.strPlural) <= n {
+				cur.strPlural = append(cur.strPlural, "")
+			}
+			cur.strPlural[n] = s
+			last = &cur.strPlural[n]
+		default:
+			return nil, fmt.Errorf("line %d: unknown keyword %s", lineNo, keyword)
+		}
+	}
+	flush()
+
+	return f, nil
+}
+
+func newPOHeader(code string) *poEntry {
+	str := ""
+	if code != "" {
+		str += "Language: " + strings.ReplaceAll(code, "-", "_") + "\n"
+	}
+	str += "MIME-Version: 1.0\n" +
+		"Content-Type: text/plain; charset=UTF-8\n" +
+		"Content-Transfer-Encoding: 8bit\n" +
+		"Plural-Forms: " + poPluralFormsFor(code) + "\n"
+
+	return &poEntry{
+		hasID:    true,
+		str:      str,
+		modified: true,
+	}
+}
+
+func (f *poFile) headerField(name string) string {
+	if f.header == nil {
+		return ""
+	}
+	for _, line := range strings.Split(f.header.str, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(k), name) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func (f *poFile) nplurals(code string) int {
+	if n := poNPlurals(f.headerField("Plural-Forms")); n > 0 {
+		return n
+	}
+	return poNPlurals(poPluralFormsFor(code))
+}
+
+func (e *poEntry) key() string {
+	if e.hasContext {
+		return e.context + poContextSeparator + e.id
+	}
+	return e.id
+}
+
+// update sets the msgstr from the items, and marks the entry as modified if it changed
+func (e *poEntry) update(items structs.JSONMap, nplurals int) {
+	key := e.key()
+	if !e.hasPlural {
+		if _, ok := items[key]; ok {
+			if v := items.GetString(key); v != e.str {
+				e.str = v
+				e.modified = true
+			}
+		}
+		return
+	}
+
+	strs := make([]string, nplurals)
+	for i := range strs {
+		if i < len(e.strPlural) {
+			strs[i] = e.strPlural[i]
+		}
+		pk := poPluralKey(key, i)
+		if _, ok := items[pk]; ok {
+			strs[i] = items.GetString(pk)
+		}
+	}
+	if len(strs) != len(e.strPlural) {
+		e.modified = true
+	} else {
+		for i := range strs {
+			if strs[i] != e.strPlural[i] {
+				e.modified = true
+				break
+			}
+		}
+	}
+	e.strPlural = strs
+}
+
+func (e *poEntry) write(buf *bytes.Buffer) {
+	if !e.modified && e.raw != nil {
+		for _, line := range e.raw {
+			buf.WriteString(line)
+			buf.WriteString("\n")
+		}
+		return
+	}
+
+	for _, line := range e.comments {
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	if !e.hasID {
+		return
+	}
+	if e.hasContext {
+		writePOString(buf, "msgctxt", e.context)
+	}
+	writePOString(buf, "msgid", e.id)
+	if !e.hasPlural {
+		writePOString(buf, "msgstr", e.str)
+		return
+	}
+	writePOString(buf, "msgid_plural", e.idPlural)
+	for i, s := range e.strPlural {
+		writePOString(buf, fmt.Sprintf("msgstr[%d]", i), s)
+	}
+}
+
+func writePOString(buf *bytes.Buffer, keyword, s string) {
+	// split multi-line strings after each newline, like msgmerge does
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		buf.WriteString(keyword + " " + poQuote(s) + "\n")
+		return
+	}
+	buf.WriteString(keyword + " \"\"\n")
+	for _, line := range lines {
+		buf.WriteString(poQuote(line) + "\n")
+	}
+}
+
+func poPluralKey(key string, n int) string {
+	return fmt.Sprintf("%s[%d]", key, n)
+}
+
+func poPluralFormsFor(code string) string {
+	tag, err := language.Parse(code)
+	if err != nil {
+		return poDefaultPluralForm
+	}
+	if s, ok := poPluralForms[tag.String()]; ok {
+		return s
+	}
+	base, _ := tag.Base()
+	if s, ok := poPluralForms[base.String()]; ok {
+		return s
+	}
+	return poDefaultPluralForm
+}
+
+func poNPlurals(pluralForms string) int {
+	m := poNPluralsRe.FindStringSubmatch(pluralForms)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
+
+func poQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func poUnquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	s = s[1 : len(s)-1]
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("invalid escape at the end of %q", s)
+		}
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'v':
+			sb.WriteByte('\v')
+		default:
+			// \" \\ \' \?
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
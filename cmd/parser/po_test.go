@@ -0,0 +1,158 @@
+package parser
+
+import "testing"
+
+const poTestSource = `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+#: main.c:10
+msgctxt "menu"
+msgid "Open"
+msgstr ""
+
+msgid "Open"
+msgstr ""
+
+#, c-format
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+`
+
+func TestPOParseSource(t *testing.T) {
+	src := parseContent(t, poFormat{}, "en", poTestSource, true)
+	want := map[string]string{
+		"menu\x04Open": "Open",
+		"Open":         "Open",
+		"%d file[0]":   "%d file",
+		"%d file[1]":   "%d files",
+	}
+	if len(src.LocaleItemsMap) != len(want) {
+		t.Errorf("got items %q, want %q", src.LocaleItemsMap, want)
+	}
+	for k, v := range want {
+		if got := src.LocaleItemsMap.GetString(k); got != v {
+			t.Errorf("%q = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestPOExpandSource(t *testing.T) {
+	src := parseContent(t, poFormat{}, "en", poTestSource, true)
+	tests := []struct {
+		code string
+		want map[string]string
+	}{
+		{"ja", map[string]string{"%d file[0]": "%d files"}},
+		{"ru", map[string]string{"%d file[0]": "%d file", "%d file[1]": "%d files", "%d file[2]": "%d files"}},
+	}
+	for _, tt := range tests {
+		target := parseContent(t, poFormat{}, tt.code, "", false)
+		items := src.SourceItemsFor(target)
+		for k, v := range tt.want {
+			if got := items.GetString(k); got != v {
+				t.Errorf("%s: %q = %q, want %q", tt.code, k, got, v)
+			}
+		}
+		if n := len(items) - 2; n != len(tt.want) {
+			t.Errorf("%s: got %d plural forms, want %d", tt.code, n, len(tt.want))
+		}
+	}
+}
+
+func TestPOMarshalNewTarget(t *testing.T) {
+	src := parseContent(t, poFormat{}, "en", poTestSource, true)
+	target := parseContent(t, poFormat{}, "ru", "", false)
+	target.Source = src
+	got := marshalWith(t, target, map[string]string{
+		"menu\x04Open": "Открыть",
+		"Open":         "Открыто",
+		"%d file[0]":   "%d файл",
+		"%d file[1]":   "%d файла",
+		"%d file[2]":   "%d файлов",
+	})
+	want := `msgid ""
+msgstr ""
+"Language: ru\n"
+"MIME-Version: 1.0\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Content-Transfer-Encoding: 8bit\n"
+"Plural-Forms: nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+#: main.c:10
+msgctxt "menu"
+msgid "Open"
+msgstr "Открыть"
+
+msgid "Open"
+msgstr "Открыто"
+
+#, c-format
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d файл"
+msgstr[1] "%d файла"
+msgstr[2] "%d файлов"
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPORoundTrip(t *testing.T) {
+	text := `# Russian translation
+msgid ""
+msgstr ""
+"Language: ru\n"
+"Plural-Forms: nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+#: main.c:10
+msgctxt "menu"
+msgid "Open"
+msgstr "Открыть"
+
+#, c-format
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d файл"
+msgstr[1] "%d файла"
+msgstr[2] "%d файлов"
+
+msgid ""
+"a long "
+"text"
+msgstr ""
+"длинный "
+"текст"
+
+#~ msgid "Old"
+#~ msgstr "Старый"
+`
+	tests := []struct {
+		name  string
+		items map[string]string
+		want  string
+	}{
+		{"unchanged", nil, text},
+		{
+			name:  "context and plural form",
+			items: map[string]string{"menu\x04Open": "Открыть файл", "%d file[1]": "%d файла!"},
+			want: replaceOnce(replaceOnce(text,
+				`msgstr "Открыть"`, `msgstr "Открыть файл"`),
+				`msgstr[1] "%d файла"`, `msgstr[1] "%d файла!"`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseContent(t, poFormat{}, "ru", text, false)
+			if v := l.LocaleItemsMap.GetString("a long text"); v != "длинный текст" {
+				t.Errorf("multi line msgstr = %q", v)
+			}
+			if got := marshalWith(t, l, tt.items); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
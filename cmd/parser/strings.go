@@ -0,0 +1,363 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lyricat/goutils/structs"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Apple .strings files, one file per language, e.g. "ja.lproj/Localizable.strings".
+//
+// Comments and whitespace around the entries are kept, only the entries whose
+// value changes are rewritten.
+
+type (
+	appleStringsFormat struct{}
+
+	appleStringsFile struct {
+		entries []*appleStringsEntry
+		// trailer is the text after the last entry
+		trailer string
+
+		utf16 bool
+		bom   bool
+	}
+
+	appleStringsEntry struct {
+		// prefix is the whitespace and comments before the entry
+		prefix string
+		// comment is the text of the last comment before the entry
+		comment string
+		key     string
+		value   string
+		// raw is the original text from the key to the semicolon
+		raw      string
+		modified bool
+	}
+)
+
+const utf8BOM = "\ufeff"
+
+func init() {
+	RegisterFormat(appleStringsFormat{})
+}
+
+func (appleStringsFormat) Name() string {
+	return "strings"
+}
+
+func (appleStringsFormat) Extensions() []string {
+	return []string{".strings"}
+}
+
+func (appleStringsFormat) Parse(l *LocaleFileContent, buf []byte) error {
+	f, err := parseAppleStrings(buf)
+	if err != nil {
+		return err
+	}
+	l.formatData = f
+
+	result := structs.NewJSONMap()
+	for _, e := range f.entries {
+		result.SetValue(e.key, e.value)
+	}
+	l.LocaleItemsMap = result
+	return nil
+}
+
+func (appleStringsFormat) Marshal(l *LocaleFileContent) ([]byte, error) {
+	f, _ := l.formatData.(*appleStringsFile)
+	if f == nil {
+		f = &appleStringsFile{}
+		l.formatData = f
+	}
+
+	known := make(map[string]bool)
+	for _, e := range f.entries {
+		known[e.key] = true
+		if _, ok := l.LocaleItemsMap[e.key]; ok {
+			if v := l.LocaleItemsMap.GetString(e.key); v != e.value {
+				e.value = v
+				e.modified = true
+			}
+		}
+	}
+
+	// add the translated entries which only exist in the source
+	if l.Source != nil {
+		if src, ok := l.Source.formatData.(*appleStringsFile); ok {
+			for _, se := range src.entries {
+				if known[se.key] || l.LocaleItemsMap.GetString(se.key) == "" {
+					continue
+				}
+				prefix := ""
+				if len(f.entries) > 0 || f.trailer != "" {
+					prefix = "\n"
+					if !strings.HasSuffix(f.trailer, "\n") {
+						prefix = "\n\n"
+					}
+				}
+				if se.comment != "" {
+					prefix += "/* " + se.comment + " */\n"
+				}
+				f.entries = append(f.entries, &appleStringsEntry{
+					prefix:   f.trailer + prefix,
+					key:      se.key,
+					value:    l.LocaleItemsMap.GetString(se.key),
+					modified: true,
+				})
+				f.trailer = "\n"
+				known[se.key] = true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if f.bom && !f.utf16 {
+		buf.WriteString(utf8BOM)
+	}
+	for _, e := range f.entries {
+		buf.WriteString(e.prefix)
+		if e.modified || e.raw == "" {
+			buf.WriteString(appleStringsQuote(e.key) + " = " + appleStringsQuote(e.value) + ";")
+		} else {
+			buf.WriteString(e.raw)
+		}
+	}
+	buf.WriteString(f.trailer)
+
+	if f.utf16 {
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes(buf.Bytes())
+	}
+	return buf.Bytes(), nil
+}
+
+// Remove deletes the entries of the keys, with the comments before them
+func (appleStringsFormat) Remove(l *LocaleFileContent, keys []string) error {
+	f, _ := l.formatData.(*appleStringsFile)
+	if f == nil {
+		return nil
+	}
+
+	remove := make(map[string]bool)
+	for _, key := range keys {
+		remove[key] = true
+	}
+
+	entries := make([]*appleStringsEntry, 0, len(f.entries))
+	// lead is the space at the start of the file, it is kept if the first entries are removed
+	lead := ""
+	for ix, e := range f.entries {
+		if remove[e.key] {
+			if len(entries) == 0 && ix == 0 {
+				lead = e.prefix[:len(e.prefix)-len(strings.TrimLeft(e.prefix, " \t\r\n"))]
+			}
+			continue
+		}
+		if len(entries) == 0 && ix > 0 {
+			e.prefix = lead + strings.TrimLeft(e.prefix, " \t\r\n")
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 && len(f.entries) > 0 {
+		f.trailer = lead + strings.TrimLeft(f.trailer, " \t\r\n")
+	}
+	f.entries = entries
+	return nil
+}
+
+func parseAppleStrings(buf []byte) (*appleStringsFile, error) {
+	f := &appleStringsFile{}
+
+	if bytes.HasPrefix(buf, []byte{0xFF, 0xFE}) || bytes.HasPrefix(buf, []byte{0xFE, 0xFF}) {
+		decoded, err := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = decoded
+		f.utf16 = true
+	}
+	text := string(buf)
+	if strings.HasPrefix(text, utf8BOM) {
+		text = text[len(utf8BOM):]
+		f.bom = true
+	}
+
+	p := &appleStringsParser{text: text}
+	for {
+		start := p.pos
+		comment, err := p.skipSpaceAndComments()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(text) {
+			f.trailer = text[start:]
+			break
+		}
+
+		e := &appleStringsEntry{
+			prefix:  text[start:p.pos],
+			comment: comment,
+		}
+		entryStart := p.pos
+
+		if e.key, err = p.readKey(); err != nil {
+			return nil, err
+		}
+		if err = p.expect('='); err != nil {
+			return nil, err
+		}
+		if _, err = p.skipSpaceAndComments(); err != nil {
+			return nil, err
+		}
+		if e.value, err = p.readQuoted(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(';'); err != nil {
+			return nil, err
+		}
+
+		e.raw = text[entryStart:p.pos]
+		f.entries = append(f.entries, e)
+	}
+
+	return f, nil
+}
+
+type appleStringsParser struct {
+	text string
+	pos  int
+}
+
+func (p *appleStringsParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.text[:p.pos], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skipSpaceAndComments returns the text of the last comment it skips
+func (p *appleStringsParser) skipSpaceAndComments() (string, error) {
+	comment := ""
+	for p.pos < len(p.text) {
+		rest := p.text[p.pos:]
+		switch {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r':
+			p.pos++
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				return "", p.errorf("unterminated comment")
+			}
+			comment = strings.TrimSpace(rest[2 : end+2])
+			p.pos += end + 4
+		case strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			comment = strings.TrimSpace(rest[2:end])
+			p.pos += end
+		default:
+			return comment, nil
+		}
+	}
+	return comment, nil
+}
+
+func (p *appleStringsParser) expect(c byte) error {
+	if _, err := p.skipSpaceAndComments(); err != nil {
+		return err
+	}
+	if p.pos >= len(p.text) || p.text[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *appleStringsParser) readKey() (string, error) {
+	if p.text[p.pos] == '"' {
+		return p.readQuoted()
+	}
+	// unquoted keys end at whitespace or "="
+	start := p.pos
+	for p.pos < len(p.text) && !strings.ContainsRune(" \t\r\n=", rune(p.text[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a key")
+	}
+	return p.text[start:p.pos], nil
+}
+
+func (p *appleStringsParser) readQuoted() (string, error) {
+	if p.pos >= len(p.text) || p.text[p.pos] != '"' {
+		return "", p.errorf("expected a string")
+	}
+	p.pos++
+
+	var sb strings.Builder
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if p.pos >= len(p.text) {
+				return "", p.errorf("unterminated string")
+			}
+			esc := p.text[p.pos]
+			p.pos++
+			switch esc {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'U', 'u':
+				if p.pos+4 > len(p.text) {
+					return "", p.errorf("invalid unicode escape")
+				}
+				n, err := strconv.ParseUint(p.text[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid unicode escape")
+				}
+				sb.WriteRune(rune(n))
+				p.pos += 4
+			default:
+				sb.WriteByte(esc)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func appleStringsQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
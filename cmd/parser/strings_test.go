@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+const stringsTestTarget = `/* The title */
+"title" = "Titre";
+
+// unquoted key and escapes
+greeting = "Bonjour\n\"%@\"";
+"bye"="Au revoir" ;
+`
+
+func TestAppleStringsParse(t *testing.T) {
+	l := parseContent(t, appleStringsFormat{}, "fr", stringsTestTarget, false)
+	want := map[string]string{
+		"title":    "Titre",
+		"greeting": "Bonjour\n\"%@\"",
+		"bye":      "Au revoir",
+	}
+	if len(l.LocaleItemsMap) != len(want) {
+		t.Errorf("got items %q, want %q", l.LocaleItemsMap, want)
+	}
+	for k, v := range want {
+		if got := l.LocaleItemsMap.GetString(k); got != v {
+			t.Errorf("%q = %q, want %q", k, got, v)
+		}
+	}
+
+	for _, text := range []string{`"a" = "b"`, `"a" = "b;`, `"a" "b";`, `/* open`} {
+		if _, err := parseAppleStrings([]byte(text)); err == nil {
+			t.Errorf("parseAppleStrings(%q) succeeds", text)
+		}
+	}
+}
+
+func TestAppleStringsMarshal(t *testing.T) {
+	source := parseContent(t, appleStringsFormat{}, "en", `/* The title */
+"title" = "Title";
+greeting = "Hello\n\"%@\"";
+"bye" = "Bye";
+/* Shown on the button */
+"ok" = "OK";
+`, true)
+
+	tests := []struct {
+		name  string
+		items map[string]string
+		want  string
+	}{
+		{"unchanged", nil, stringsTestTarget},
+		{
+			name:  "changed value is quoted",
+			items: map[string]string{"bye": "Salut\t\"toi\""},
+			want:  replaceOnce(stringsTestTarget, `"bye"="Au revoir" ;`, `"bye" = "Salut\t\"toi\"";`),
+		},
+		{
+			name:  "new key with the source comment",
+			items: map[string]string{"ok": "D'accord"},
+			want:  stringsTestTarget + "\n/* Shown on the button */\n\"ok\" = \"D'accord\";\n",
+		},
+		{"untranslated key is not written", map[string]string{"ok": ""}, stringsTestTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseContent(t, appleStringsFormat{}, "fr", stringsTestTarget, false)
+			l.Source = source
+			if got := marshalWith(t, l, tt.items); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppleStringsRemove(t *testing.T) {
+	l := parseContent(t, appleStringsFormat{}, "fr", stringsTestTarget, false)
+	if err := l.Remove([]string{"title", "bye"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "// unquoted key and escapes\ngreeting = \"Bonjour\\n\\\"%@\\\"\";\n"
+	if got := marshalWith(t, l, nil); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAppleStringsUTF16(t *testing.T) {
+	text := "\"title\" = \"タイトル\";\n"
+	buf, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &LocaleFileContent{Code: "ja", Format: appleStringsFormat{}}
+	if err := (appleStringsFormat{}).Parse(l, buf); err != nil {
+		t.Fatal(err)
+	}
+	if v := l.LocaleItemsMap.GetString("title"); v != "タイトル" {
+		t.Errorf("title = %q", v)
+	}
+	out, err := l.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(buf) {
+		t.Errorf("UTF-16 file is not written back as it was: % x", out)
+	}
+}
@@ -0,0 +1,420 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+// Apple .stringsdict files, one file per language, e.g. "ja.lproj/Localizable.stringsdict".
+//
+// The format string of an entry uses the entry key, e.g. "%d files", and is only
+// translated if it has text besides the variables. The plural forms of a variable
+// use "<key>:<variable>[<category>]", e.g. "%d files:files[one]".
+
+type (
+	stringsdictFormat struct{}
+
+	// plistNode is a property list value. Dictionaries keep their key order.
+	plistNode struct {
+		// kind is the element name, e.g. "dict", "array", "string", "integer"
+		kind  string
+		keys  []string
+		items []*plistNode
+		text  string
+	}
+)
+
+const (
+	stringsdictFormatKey = "NSStringLocalizedFormatKey"
+	stringsdictSpecKey   = "NSStringFormatSpecTypeKey"
+	stringsdictPluralVal = "NSStringPluralRuleType"
+
+	plistHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+`
+	plistFooter = "</plist>\n"
+)
+
+var (
+	stringsdictVariableRe = regexp.MustCompile(`%(\d+\$)?#@[^@]*@`)
+)
+
+func init() {
+	RegisterFormat(stringsdictFormat{})
+}
+
+func (stringsdictFormat) Name() string {
+	return "stringsdict"
+}
+
+func (stringsdictFormat) Extensions() []string {
+	return []string{".stringsdict"}
+}
+
+func (stringsdictFormat) Parse(l *LocaleFileContent, buf []byte) error {
+	root, err := parsePlist(buf)
+	if err != nil {
+		return err
+	}
+	l.formatData = root
+
+	result := structs.NewJSONMap()
+	for ix, key := range root.keys {
+		entry := root.items[ix]
+		if format := entry.get(stringsdictFormatKey); format != nil && stringsdictHasText(format.text) {
+			result.SetValue(key, format.text)
+		}
+		for vx, name := range entry.keys {
+			variable := entry.items[vx]
+			if !variable.isPluralVariable() {
+				continue
+			}
+			for cx, cat := range variable.keys {
+				if isPluralCategory(cat) {
+					result.SetValue(pluralKey(key+":"+name, cat), variable.items[cx].text)
+				}
+			}
+		}
+	}
+
+	l.LocaleItemsMap = result
+	return nil
+}
+
+// ExpandSource builds one item per plural category of the target language
+func (stringsdictFormat) ExpandSource(source, target *LocaleFileContent) structs.JSONMap {
+	root, ok := source.formatData.(*plistNode)
+	if !ok {
+		return source.LocaleItemsMap
+	}
+
+	categories := PluralCategories(target.Code)
+	result := structs.NewJSONMap()
+	for ix, key := range root.keys {
+		entry := root.items[ix]
+		if format := entry.get(stringsdictFormatKey); format != nil && stringsdictHasText(format.text) {
+			result.SetValue(key, format.text)
+		}
+		for vx, name := range entry.keys {
+			variable := entry.items[vx]
+			if !variable.isPluralVariable() {
+				continue
+			}
+			cats := categories
+			if variable.get("zero") != nil && cats[0] != "zero" {
+				cats = append([]string{"zero"}, cats...)
+			}
+			for _, cat := range cats {
+				form := variable.get(cat)
+				if form == nil {
+					form = variable.get("other")
+				}
+				text := ""
+				if form != nil {
+					text = form.text
+				}
+				result.SetValue(pluralKey(key+":"+name, cat), text)
+			}
+		}
+	}
+	return result
+}
+
+func (stringsdictFormat) Marshal(l *LocaleFileContent) ([]byte, error) {
+	root, _ := l.formatData.(*plistNode)
+	if root == nil {
+		root = &plistNode{kind: "dict"}
+		l.formatData = root
+	}
+
+	for ix, key := range root.keys {
+		stringsdictUpdate(root.items[ix], key, l.LocaleItemsMap)
+	}
+
+	// add the translated entries which only exist in the source
+	if l.Source != nil {
+		if src, ok := l.Source.formatData.(*plistNode); ok {
+			for ix, key := range src.keys {
+				if root.get(key) != nil || !stringsdictHasItems(key, l.LocaleItemsMap) {
+					continue
+				}
+				entry := src.items[ix].clone()
+				// the forms of the source language are replaced by the target forms
+				for _, variable := range entry.items {
+					if variable.isPluralVariable() {
+						for _, cat := range pluralFormNames {
+							variable.remove(cat)
+						}
+					}
+				}
+				stringsdictUpdate(entry, key, l.LocaleItemsMap)
+				root.set(key, entry)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(plistHeader)
+	root.write(&buf, "")
+	buf.WriteString("\n")
+	buf.WriteString(plistFooter)
+	return buf.Bytes(), nil
+}
+
+// Remove deletes the plural forms of the keys, and the entries whose items are all removed
+func (stringsdictFormat) Remove(l *LocaleFileContent, keys []string) error {
+	root, _ := l.formatData.(*plistNode)
+	if root == nil {
+		return nil
+	}
+
+	remove := make(map[string]bool)
+	for _, key := range keys {
+		remove[key] = true
+	}
+
+	for _, key := range append([]string(nil), root.keys...) {
+		entry := root.get(key)
+		kept, removed := 0, 0
+		if format := entry.get(stringsdictFormatKey); format != nil && stringsdictHasText(format.text) {
+			if remove[key] {
+				removed += 1
+			} else {
+				kept += 1
+			}
+		}
+		for vx, name := range entry.keys {
+			variable := entry.items[vx]
+			if !variable.isPluralVariable() {
+				continue
+			}
+			for _, cat := range append([]string(nil), variable.keys...) {
+				if !isPluralCategory(cat) {
+					continue
+				}
+				if remove[pluralKey(key+":"+name, cat)] {
+					removed += 1
+					// "other" is required by the format
+					if cat != "other" {
+						variable.remove(cat)
+					}
+				} else {
+					kept += 1
+				}
+			}
+		}
+		if removed > 0 && kept == 0 {
+			root.remove(key)
+		}
+	}
+	return nil
+}
+
+// stringsdictHasText reports whether the format string has text besides the variables
+func stringsdictHasText(format string) bool {
+	return strings.TrimSpace(stringsdictVariableRe.ReplaceAllString(format, "")) != ""
+}
+
+func stringsdictHasItems(key string, items structs.JSONMap) bool {
+	if items.GetString(key) != "" {
+		return true
+	}
+	for k := range items {
+		if strings.HasPrefix(k, key+":") && items.GetString(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// stringsdictUpdate sets the format string and the plural forms of the entry from the items
+func stringsdictUpdate(entry *plistNode, key string, items structs.JSONMap) {
+	if format := entry.get(stringsdictFormatKey); format != nil {
+		if _, ok := items[key]; ok {
+			format.text = items.GetString(key)
+		}
+	}
+
+	for vx, name := range entry.keys {
+		variable := entry.items[vx]
+		if !variable.isPluralVariable() {
+			continue
+		}
+		for _, form := range pluralForms {
+			cat := pluralFormNames[form]
+			pk := pluralKey(key+":"+name, cat)
+			if _, ok := items[pk]; !ok {
+				continue
+			}
+			if node := variable.get(cat); node != nil {
+				node.text = items.GetString(pk)
+			} else {
+				variable.set(cat, &plistNode{kind: "string", text: items.GetString(pk)})
+			}
+		}
+	}
+}
+
+func (n *plistNode) isPluralVariable() bool {
+	if n.kind != "dict" {
+		return false
+	}
+	spec := n.get(stringsdictSpecKey)
+	return spec != nil && spec.text == stringsdictPluralVal
+}
+
+func (n *plistNode) get(key string) *plistNode {
+	for ix, k := range n.keys {
+		if k == key {
+			return n.items[ix]
+		}
+	}
+	return nil
+}
+
+func (n *plistNode) set(key string, value *plistNode) {
+	for ix, k := range n.keys {
+		if k == key {
+			n.items[ix] = value
+			return
+		}
+	}
+	n.keys = append(n.keys, key)
+	n.items = append(n.items, value)
+}
+
+func (n *plistNode) remove(key string) {
+	for ix, k := range n.keys {
+		if k == key {
+			n.keys = append(n.keys[:ix], n.keys[ix+1:]...)
+			n.items = append(n.items[:ix], n.items[ix+1:]...)
+			return
+		}
+	}
+}
+
+func (n *plistNode) clone() *plistNode {
+	c := &plistNode{
+		kind: n.kind,
+		keys: append([]string(nil), n.keys...),
+		text: n.text,
+	}
+	for _, item := range n.items {
+		c.items = append(c.items, item.clone())
+	}
+	return c
+}
+
+// write renders the node with tabs, the way Xcode does
+func (n *plistNode) write(buf *bytes.Buffer, indent string) {
+	buf.WriteString(indent)
+	switch n.kind {
+	case "dict":
+		if len(n.keys) == 0 {
+			buf.WriteString("<dict/>")
+			return
+		}
+		buf.WriteString("<dict>\n")
+		for ix, key := range n.keys {
+			buf.WriteString(indent + "\t<key>" + plistEscape(key) + "</key>\n")
+			n.items[ix].write(buf, indent+"\t")
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "</dict>")
+	case "array":
+		if len(n.items) == 0 {
+			buf.WriteString("<array/>")
+			return
+		}
+		buf.WriteString("<array>\n")
+		for _, item := range n.items {
+			item.write(buf, indent+"\t")
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "</array>")
+	case "true", "false":
+		buf.WriteString("<" + n.kind + "/>")
+	default:
+		buf.WriteString("<" + n.kind + ">" + plistEscape(n.text) + "</" + n.kind + ">")
+	}
+}
+
+func plistEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func parsePlist(buf []byte) (*plistNode, error) {
+	if len(bytes.TrimSpace(buf)) == 0 {
+		return &plistNode{kind: "dict"}, nil
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(buf))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the property list has no value")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local != "plist" {
+			root, err := parsePlistNode(dec, se)
+			if err != nil {
+				return nil, err
+			}
+			if root.kind != "dict" {
+				return nil, fmt.Errorf("the property list root is %s, not dict", root.kind)
+			}
+			return root, nil
+		}
+	}
+}
+
+func parsePlistNode(dec *xml.Decoder, start xml.StartElement) (*plistNode, error) {
+	n := &plistNode{kind: start.Name.Local}
+	key := ""
+	hasKey := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "key" {
+				var s string
+				if err := dec.DecodeElement(&s, &t); err != nil {
+					return nil, err
+				}
+				key = s
+				hasKey = true
+				continue
+			}
+			child, err := parsePlistNode(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			if n.kind == "dict" {
+				if !hasKey {
+					return nil, fmt.Errorf("%s without key in dict", child.kind)
+				}
+				n.keys = append(n.keys, key)
+				hasKey = false
+			}
+			n.items = append(n.items, child)
+		case xml.CharData:
+			if n.kind != "dict" && n.kind != "array" {
+				n.text += string(t)
+			}
+		case xml.EndElement:
+			return n, nil
+		}
+	}
+}
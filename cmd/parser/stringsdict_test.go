@@ -0,0 +1,161 @@
+package parser
+
+import "testing"
+
+const stringsdictTestSource = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>files</key>
+	<dict>
+		<key>NSStringLocalizedFormatKey</key>
+		<string>%#@files@</string>
+		<key>files</key>
+		<dict>
+			<key>NSStringFormatSpecTypeKey</key>
+			<string>NSStringPluralRuleType</string>
+			<key>NSStringFormatValueTypeKey</key>
+			<string>d</string>
+			<key>one</key>
+			<string>%d file</string>
+			<key>other</key>
+			<string>%d files</string>
+		</dict>
+	</dict>
+	<key>left</key>
+	<dict>
+		<key>NSStringLocalizedFormatKey</key>
+		<string>%#@days@ left</string>
+		<key>days</key>
+		<dict>
+			<key>NSStringFormatSpecTypeKey</key>
+			<string>NSStringPluralRuleType</string>
+			<key>NSStringFormatValueTypeKey</key>
+			<string>d</string>
+			<key>other</key>
+			<string>%d days</string>
+		</dict>
+	</dict>
+</dict>
+</plist>
+`
+
+func TestStringsdictParse(t *testing.T) {
+	l := parseContent(t, stringsdictFormat{}, "en", stringsdictTestSource, false)
+	want := map[string]string{
+		"files:files[one]":   "%d file",
+		"files:files[other]": "%d files",
+		"left":               "%#@days@ left",
+		"left:days[other]":   "%d days",
+	}
+	if len(l.LocaleItemsMap) != len(want) {
+		t.Errorf("got items %q, want %q", l.LocaleItemsMap, want)
+	}
+	for k, v := range want {
+		if got := l.LocaleItemsMap.GetString(k); got != v {
+			t.Errorf("%q = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestStringsdictExpandSource(t *testing.T) {
+	source := parseContent(t, stringsdictFormat{}, "en", stringsdictTestSource, false)
+	target := parseContent(t, stringsdictFormat{}, "ru", "", false)
+	items := (stringsdictFormat{}).ExpandSource(source, target)
+	want := map[string]string{
+		"files:files[one]":   "%d file",
+		"files:files[few]":   "%d files",
+		"files:files[many]":  "%d files",
+		"files:files[other]": "%d files",
+		"left":               "%#@days@ left",
+		"left:days[one]":     "%d days",
+		"left:days[few]":     "%d days",
+		"left:days[many]":    "%d days",
+		"left:days[other]":   "%d days",
+	}
+	if len(items) != len(want) {
+		t.Errorf("got items %q, want %q", items, want)
+	}
+	for k, v := range want {
+		if got := items.GetString(k); got != v {
+			t.Errorf("%q = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestStringsdictMarshal(t *testing.T) {
+	source := parseContent(t, stringsdictFormat{}, "en", stringsdictTestSource, false)
+	target := parseContent(t, stringsdictFormat{}, "ru", "", false)
+	target.Source = source
+	got := marshalWith(t, target, map[string]string{
+		"files:files[one]":   "%d файл",
+		"files:files[few]":   "%d файла",
+		"files:files[many]":  "%d файлов",
+		"files:files[other]": "%d файла",
+	})
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>files</key>
+	<dict>
+		<key>NSStringLocalizedFormatKey</key>
+		<string>%#@files@</string>
+		<key>files</key>
+		<dict>
+			<key>NSStringFormatSpecTypeKey</key>
+			<string>NSStringPluralRuleType</string>
+			<key>NSStringFormatValueTypeKey</key>
+			<string>d</string>
+			<key>one</key>
+			<string>%d файл</string>
+			<key>few</key>
+			<string>%d файла</string>
+			<key>many</key>
+			<string>%d файлов</string>
+			<key>other</key>
+			<string>%d файла</string>
+		</dict>
+	</dict>
+</dict>
+</plist>
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// an unchanged file is written back as it was
+	l := parseContent(t, stringsdictFormat{}, "en", stringsdictTestSource, false)
+	if got := marshalWith(t, l, nil); got != stringsdictTestSource {
+		t.Errorf("got:\n%s\nwant:\n%s", got, stringsdictTestSource)
+	}
+}
+
+func TestStringsdictRemove(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want map[string]bool
+	}{
+		{"plural form", []string{"files:files[one]"}, map[string]bool{"files:files[other]": true, "left": true, "left:days[other]": true}},
+		{"other form is required", []string{"files:files[other]"}, map[string]bool{"files:files[one]": true, "files:files[other]": true, "left": true, "left:days[other]": true}},
+		{"whole entry", []string{"left", "left:days[other]"}, map[string]bool{"files:files[one]": true, "files:files[other]": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseContent(t, stringsdictFormat{}, "en", stringsdictTestSource, false)
+			if err := l.Remove(tt.keys); err != nil {
+				t.Fatal(err)
+			}
+			back := parseContent(t, stringsdictFormat{}, "en", marshalWith(t, l, nil), false)
+			if len(back.LocaleItemsMap) != len(tt.want) {
+				t.Errorf("got items %q, want the keys %v", back.LocaleItemsMap, tt.want)
+			}
+			for k := range tt.want {
+				if _, ok := back.LocaleItemsMap[k]; !ok {
+					t.Errorf("%q is removed", k)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+// Apple String Catalogs (.xcstrings).
+//
+// One catalog keeps every language, so the source and all targets share the
+// decoded file. Keys are the catalog keys, plural variations use one key per
+// category, e.g. "%lld items[one]". Unknown fields are kept as they are.
+
+type (
+	xcstringsFormat struct{}
+
+	xcstringsFile struct {
+		data map[string]interface{}
+		// trailingNewline is true if the file ends with a newline
+		trailingNewline bool
+	}
+)
+
+const (
+	xcstringsStateTranslated = "translated"
+)
+
+func init() {
+	RegisterFormat(xcstringsFormat{})
+}
+
+func (xcstringsFormat) Name() string {
+	return "xcstrings"
+}
+
+func (xcstringsFormat) Extensions() []string {
+	return []string{".xcstrings"}
+}
+
+func (xcstringsFormat) Parse(l *LocaleFileContent, buf []byte) error {
+	f, err := parseXcstrings(buf)
+	if err != nil {
+		return err
+	}
+	l.formatData = f
+	l.LocaleItemsMap = f.items(l.Code, false)
+	return nil
+}
+
+// ParseSource reads the source language of the catalog
+func (xcstringsFormat) ParseSource(l *LocaleFileContent, buf []byte) error {
+	f, err := parseXcstrings(buf)
+	if err != nil {
+		return err
+	}
+
+	code := f.sourceLanguage()
+	lang, err := langCodeToName(code)
+	if err != nil {
+		return fmt.Errorf("invalid source language %q: %w", code, err)
+	}
+
+	l.Code = code
+	l.Lang = lang
+	l.formatData = f
+	l.LocaleItemsMap = f.items(code, true)
+	return nil
+}
+
+func (xcstringsFormat) Languages(l *LocaleFileContent) []string {
+	f, ok := l.formatData.(*xcstringsFile)
+	if !ok {
+		return nil
+	}
+
+	found := map[string]bool{f.sourceLanguage(): true}
+	for _, v := range f.strings() {
+		for code := range xcstringsMap(v, "localizations") {
+			found[code] = true
+		}
+	}
+
+	codes := make([]string, 0, len(found))
+	for code := range found {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func (xcstringsFormat) ParseLanguage(l *LocaleFileContent) error {
+	f, ok := l.formatData.(*xcstringsFile)
+	if !ok {
+		return fmt.Errorf("file %s is not parsed as a string catalog", l.Path)
+	}
+	l.LocaleItemsMap = f.items(l.Code, false)
+	return nil
+}
+
+// ExpandSource builds one item per plural category of the target language
+func (xcstringsFormat) ExpandSource(source, target *LocaleFileContent) structs.JSONMap {
+	f, ok := source.formatData.(*xcstringsFile)
+	if !ok {
+		return source.LocaleItemsMap
+	}
+
+	categories := PluralCategories(target.Code)
+	result := structs.NewJSONMap()
+	for key, v := range f.strings() {
+		if !xcstringsShouldTranslate(v) {
+			continue
+		}
+		loc := xcstringsMap(v, "localizations", source.Code)
+		if loc == nil {
+			// the key is the source text if there is no localization
+			result.SetValue(key, key)
+			continue
+		}
+		if unit := xcstringsMap(loc, "stringUnit"); unit != nil {
+			result.SetValue(key, xcstringsString(unit, "value"))
+			continue
+		}
+		forms := xcstringsMap(loc, "variations", "plural")
+		if forms == nil {
+			continue
+		}
+		cats := categories
+		if _, ok := forms["zero"]; ok && cats[0] != "zero" {
+			cats = append([]string{"zero"}, cats...)
+		}
+		for _, cat := range cats {
+			form := xcstringsMap(forms, cat, "stringUnit")
+			if form == nil {
+				form = xcstringsMap(forms, "other", "stringUnit")
+			}
+			result.SetValue(pluralKey(key, cat), xcstringsString(form, "value"))
+		}
+	}
+	return result
+}
+
+// Marshal writes the entries of l.Code into the catalog and renders the whole catalog
+func (xcstringsFormat) Marshal(l *LocaleFileContent) ([]byte, error) {
+	f, ok := l.formatData.(*xcstringsFile)
+	if !ok {
+		return nil, fmt.Errorf("file %s is not parsed as a string catalog", l.Path)
+	}
+
+	if l.Code != f.sourceLanguage() {
+		for key, v := range f.strings() {
+			entry, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := l.LocaleItemsMap[key]; ok {
+				xcstringsSetUnit(entry, []string{"localizations", l.Code}, l.LocaleItemsMap.GetString(key))
+				continue
+			}
+			for _, cat := range pluralFormNames {
+				pk := pluralKey(key, cat)
+				if _, ok := l.LocaleItemsMap[pk]; ok {
+					xcstringsSetUnit(entry, []string{"localizations", l.Code, "variations", "plural", cat}, l.LocaleItemsMap.GetString(pk))
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeXcstringsValue(&buf, f.data, ""); err != nil {
+		return nil, err
+	}
+	if f.trailingNewline {
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+func parseXcstrings(buf []byte) (*xcstringsFile, error) {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	if _, ok := data["strings"]; !ok {
+		data["strings"] = make(map[string]interface{})
+	}
+
+	return &xcstringsFile{
+		data:            data,
+		trailingNewline: bytes.HasSuffix(buf, []byte("\n")),
+	}, nil
+}
+
+func (f *xcstringsFile) sourceLanguage() string {
+	code, _ := f.data["sourceLanguage"].(string)
+	if code == "" {
+		return "en"
+	}
+	return code
+}
+
+func (f *xcstringsFile) strings() map[string]interface{} {
+	m, _ := f.data["strings"].(map[string]interface{})
+	return m
+}
+
+// items returns the values of a language. Substitutions and device variations
+// are not supported and left untouched.
+func (f *xcstringsFile) items(code string, isSource bool) structs.JSONMap {
+	result := structs.NewJSONMap()
+	for key, v := range f.strings() {
+		if !xcstringsShouldTranslate(v) {
+			continue
+		}
+		loc := xcstringsMap(v, "localizations", code)
+		if loc == nil {
+			if isSource {
+				result.SetValue(key, key)
+			}
+			continue
+		}
+		if unit := xcstringsMap(loc, "stringUnit"); unit != nil {
+			result.SetValue(key, xcstringsString(unit, "value"))
+			continue
+		}
+		for cat, form := range xcstringsMap(loc, "variations", "plural") {
+			if unit := xcstringsMap(form, "stringUnit"); unit != nil {
+				result.SetValue(pluralKey(key, cat), xcstringsString(unit, "value"))
+			}
+		}
+	}
+	return result
+}
+
+func xcstringsShouldTranslate(entry interface{}) bool {
+	m, _ := entry.(map[string]interface{})
+	v, ok := m["shouldTranslate"].(bool)
+	return !ok || v
+}
+
+// xcstringsMap walks down the nested objects by keys, and returns nil if any is missing
+func xcstringsMap(v interface{}, keys ...string) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	for _, key := range keys {
+		if m == nil {
+			return nil
+		}
+		m, _ = m[key].(map[string]interface{})
+	}
+	return m
+}
+
+func xcstringsString(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// xcstringsSetUnit sets the stringUnit under the keys. The state is kept if the
+// value does not change, otherwise it becomes "translated".
+func xcstringsSetUnit(entry map[string]interface{}, keys []string, value string) {
+	m := entry
+	for _, key := range keys {
+		child, ok := m[key].(map[string]interface{})
+		if !ok {
+			if value == "" {
+				// do not create empty localizations
+				return
+			}
+			child = make(map[string]interface{})
+			m[key] = child
+		}
+		m = child
+	}
+
+	unit, ok := m["stringUnit"].(map[string]interface{})
+	if !ok {
+		if value == "" {
+			return
+		}
+		unit = make(map[string]interface{})
+		m["stringUnit"] = unit
+	} else if xcstringsString(unit, "value") == value {
+		return
+	}
+	unit["state"] = xcstringsStateTranslated
+	unit["value"] = value
+}
+
+// writeXcstringsValue renders the value the way Xcode does: case insensitive sorted keys,
+// two spaces of indentation and " : " between keys and values.
+func writeXcstringsValue(buf *bytes.Buffer, v interface{}, indent string) error {
+	inner := indent + "  "
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := strings.ToLower(keys[i]), strings.ToLower(keys[j])
+			if a != b {
+				return a < b
+			}
+			return keys[i] < keys[j]
+		})
+
+		buf.WriteString("{\n")
+		for ix, key := range keys {
+			buf.WriteString(inner)
+			if err := writeXcstringsValue(buf, key, inner); err != nil {
+				return err
+			}
+			buf.WriteString(" : ")
+			if err := writeXcstringsValue(buf, v[key], inner); err != nil {
+				return err
+			}
+			if ix < len(keys)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		if len(keys) == 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "}")
+	case []interface{}:
+		buf.WriteString("[\n")
+		for ix, item := range v {
+			buf.WriteString(inner)
+			if err := writeXcstringsValue(buf, item, inner); err != nil {
+				return err
+			}
+			if ix < len(v)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		if len(v) == 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "]")
+	default:
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		buf.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package parser
+
+import "testing"
+
+const xcstringsTestCatalog = `{
+  "sourceLanguage" : "en",
+  "strings" : {
+    "%lld files" : {
+      "localizations" : {
+        "en" : {
+          "variations" : {
+            "plural" : {
+              "one" : {
+                "stringUnit" : {
+                  "state" : "translated",
+                  "value" : "%lld file"
+                }
+              },
+              "other" : {
+                "stringUnit" : {
+                  "state" : "translated",
+                  "value" : "%lld files"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "Hello" : {
+      "localizations" : {
+        "ja" : {
+          "stringUnit" : {
+            "state" : "needs_review",
+            "value" : "こんにちは"
+          }
+        }
+      }
+    },
+    "ID" : {
+      "shouldTranslate" : false
+    },
+    "title" : {
+      "comment" : "The title",
+      "localizations" : {
+        "en" : {
+          "stringUnit" : {
+            "state" : "translated",
+            "value" : "Title"
+          }
+        }
+      }
+    }
+  },
+  "version" : "1.0"
+}
+`
+
+func TestXcstringsParse(t *testing.T) {
+	tests := []struct {
+		code     string
+		isSource bool
+		want     map[string]string
+	}{
+		{"en", true, map[string]string{
+			"%lld files[one]":   "%lld file",
+			"%lld files[other]": "%lld files",
+			"Hello":             "Hello",
+			"title":             "Title",
+		}},
+		{"ja", false, map[string]string{"Hello": "こんにちは"}},
+	}
+	for _, tt := range tests {
+		l := parseContent(t, xcstringsFormat{}, tt.code, xcstringsTestCatalog, tt.isSource)
+		if len(l.LocaleItemsMap) != len(tt.want) {
+			t.Errorf("%s: got items %q, want %q", tt.code, l.LocaleItemsMap, tt.want)
+		}
+		for k, v := range tt.want {
+			if got := l.LocaleItemsMap.GetString(k); got != v {
+				t.Errorf("%s: %q = %q, want %q", tt.code, k, got, v)
+			}
+		}
+		if langs := (xcstringsFormat{}).Languages(l); len(langs) != 2 || langs[0] != "en" || langs[1] != "ja" {
+			t.Errorf("got languages %q, want [en ja]", langs)
+		}
+	}
+}
+
+func TestXcstringsExpandSource(t *testing.T) {
+	source := parseContent(t, xcstringsFormat{}, "en", xcstringsTestCatalog, true)
+	target := parseContent(t, xcstringsFormat{}, "ja", xcstringsTestCatalog, false)
+	items := (xcstringsFormat{}).ExpandSource(source, target)
+	want := map[string]string{
+		"%lld files[other]": "%lld files",
+		"Hello":             "Hello",
+		"title":             "Title",
+	}
+	if len(items) != len(want) {
+		t.Errorf("got items %q, want %q", items, want)
+	}
+	for k, v := range want {
+		if got := items.GetString(k); got != v {
+			t.Errorf("%q = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestXcstringsMarshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		items map[string]string
+		want  string
+	}{
+		{"unchanged", nil, xcstringsTestCatalog},
+		{"same value keeps the state", map[string]string{"Hello": "こんにちは"}, xcstringsTestCatalog},
+		{
+			name:  "changed value",
+			items: map[string]string{"Hello": "やあ"},
+			want: replaceOnce(replaceOnce(xcstringsTestCatalog,
+				`"needs_review"`, `"translated"`),
+				`"こんにちは"`, `"やあ"`),
+		},
+		{
+			name:  "new plural and string",
+			items: map[string]string{"%lld files[other]": "%lld 個のファイル", "title": "タイトル"},
+			want: replaceOnce(replaceOnce(xcstringsTestCatalog, `
+        }
+      }
+    },
+    "Hello" : {`, `
+        },
+        "ja" : {
+          "variations" : {
+            "plural" : {
+              "other" : {
+                "stringUnit" : {
+                  "state" : "translated",
+                  "value" : "%lld 個のファイル"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "Hello" : {`), `"value" : "Title"
+          }
+        }`, `"value" : "Title"
+          }
+        },
+        "ja" : {
+          "stringUnit" : {
+            "state" : "translated",
+            "value" : "タイトル"
+          }
+        }`),
+		},
+		{"empty value is not written", map[string]string{"title": ""}, xcstringsTestCatalog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseContent(t, xcstringsFormat{}, "ja", xcstringsTestCatalog, false)
+			if got := marshalWith(t, l, tt.items); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
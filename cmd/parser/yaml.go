@@ -0,0 +1,393 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lyricat/goutils/structs"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML locale files, e.g. Rails i18n and vue-i18n.
+//
+// Nested keys are joined the same way as JSON, e.g. "home/title", and arrays
+// use one key per item, e.g. "date/day_names[0]". A top-level language key, e.g.
+// "ja:" in ja.yml, is removed when reading and restored when writing. The node
+// tree is kept, so key order and comments survive a round trip.
+
+type (
+	yamlFormat struct{}
+
+	yamlFile struct {
+		doc *yaml.Node
+		// rootKey is the top-level language key, or empty if there is none
+		rootKey string
+		indent  int
+	}
+)
+
+const yamlDefaultIndent = 2
+
+func init() {
+	RegisterFormat(yamlFormat{})
+}
+
+func (yamlFormat) Name() string {
+	return "yaml"
+}
+
+func (yamlFormat) Extensions() []string {
+	return []string{".yml", ".yaml"}
+}
+
+func (yamlFormat) Parse(l *LocaleFileContent, buf []byte) error {
+	f, err := parseYAML(buf, l.Code)
+	if err != nil {
+		return err
+	}
+	l.formatData = f
+
+	result := structs.NewJSONMap()
+	for _, key := range yamlKeys(f.content(), nil) {
+		result.SetValue(key.path, key.value())
+	}
+
+	l.LocaleItemsMap = result
+	return nil
+}
+
+func (yamlFormat) Marshal(l *LocaleFileContent) ([]byte, error) {
+	f, _ := l.formatData.(*yamlFile)
+	if f == nil || len(f.doc.Content[0].Content) == 0 {
+		f = newYAMLFile(l)
+		l.formatData = f
+	}
+	content := f.content()
+
+	// the keys of the source go first, in the order of the source
+	written := make(map[string]bool)
+	if l.Source != nil {
+		if src, ok := l.Source.formatData.(*yamlFile); ok {
+			for _, key := range yamlKeys(src.content(), nil) {
+				if _, ok := l.LocaleItemsMap[key.path]; ok {
+					setYAMLValue(content, key.parts, l.LocaleItemsMap.GetString(key.path))
+					written[key.path] = true
+				}
+			}
+		}
+	}
+
+	existing := make(map[string][]string)
+	for _, key := range yamlKeys(content, nil) {
+		existing[key.path] = key.parts
+	}
+
+	keys := make([]string, 0)
+	for key := range l.LocaleItemsMap {
+		if !written[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts, ok := existing[key]
+		if !ok {
+			parts = newKeyParts(key)
+		}
+		setYAMLValue(content, parts, l.LocaleItemsMap.GetString(key))
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(f.indent)
+	if err := enc.Encode(f.doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExpandSource builds the i18next plural forms the target language needs,
+// e.g. "files_few" and "files_many" for Polish
+func (yamlFormat) ExpandSource(source, target *LocaleFileContent) structs.JSONMap {
+	return expandSuffixPlurals(source.LocaleItemsMap, target.Code)
+}
+
+// Remove deletes the string values of the keys, and the mappings and
+// sequences which are left empty by it
+func (yamlFormat) Remove(l *LocaleFileContent, keys []string) error {
+	f, _ := l.formatData.(*yamlFile)
+	if f == nil {
+		return nil
+	}
+
+	remove := make(map[string]bool)
+	for _, key := range keys {
+		remove[key] = true
+	}
+	removeYAMLKeys(f.content(), nil, remove)
+	return nil
+}
+
+// removeYAMLKeys deletes the keys below the mapping, and reports whether all
+// of its entries are deleted
+func removeYAMLKeys(m *yaml.Node, prefix []string, remove map[string]bool) bool {
+	if len(m.Content) == 0 {
+		return false
+	}
+
+	content := make([]*yaml.Node, 0, len(m.Content))
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k, v := m.Content[i], m.Content[i+1]
+		parts := append(append([]string{}, prefix...), k.Value)
+		path := yamlPath(parts)
+		drop := false
+		switch {
+		case k.Value == "<<":
+		case v.Kind == yaml.MappingNode:
+			drop = removeYAMLKeys(v, parts, remove)
+		case v.Kind == yaml.SequenceNode && len(v.Content) > 0:
+			items := make([]*yaml.Node, 0, len(v.Content))
+			for ix, item := range v.Content {
+				if !isYAMLString(item) || !remove[indexKey(path, ix)] {
+					items = append(items, item)
+				}
+			}
+			v.Content = items
+			drop = len(items) == 0
+		case isYAMLString(v):
+			drop = remove[path]
+		}
+		if !drop {
+			content = append(content, k, v)
+		}
+	}
+	m.Content = content
+	return len(content) == 0
+}
+
+func parseYAML(buf []byte, code string) (*yamlFile, error) {
+	f := &yamlFile{
+		doc:    &yaml.Node{},
+		indent: yamlIndent(buf),
+	}
+	if err := yaml.Unmarshal(buf, f.doc); err != nil {
+		return nil, err
+	}
+	if f.doc.Kind == 0 {
+		// empty file
+		f.doc = &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+		return f, nil
+	}
+
+	root := f.doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the root of the file is not a mapping")
+	}
+
+	// a single top-level key which is the language of the file
+	if len(root.Content) == 2 && root.Content[1].Kind == yaml.MappingNode {
+		key := root.Content[0].Value
+		if sameLang(key, code) || (code == "" && isLangCode(key)) {
+			f.rootKey = key
+		}
+	}
+	return f, nil
+}
+
+// newYAMLFile creates an empty file, with a language key if the source has one
+func newYAMLFile(l *LocaleFileContent) *yamlFile {
+	f := &yamlFile{
+		doc: &yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		},
+		indent: yamlDefaultIndent,
+	}
+	if l.Source != nil {
+		if src, ok := l.Source.formatData.(*yamlFile); ok {
+			f.indent = src.indent
+			if src.rootKey != "" && l.Code != "" {
+				f.rootKey = l.Code
+				f.doc.Content[0].Content = []*yaml.Node{
+					{Kind: yaml.ScalarNode, Tag: "!!str", Value: l.Code},
+					{Kind: yaml.MappingNode, Tag: "!!map"},
+				}
+			}
+		}
+	}
+	return f
+}
+
+// content returns the mapping of the locale items, below the language key if there is one
+func (f *yamlFile) content() *yaml.Node {
+	root := f.doc.Content[0]
+	if f.rootKey != "" {
+		return root.Content[1]
+	}
+	return root
+}
+
+type yamlKey struct {
+	path string
+	// parts are the keys of the mappings, the last one may be an array item, e.g. "days[0]"
+	parts []string
+	node  *yaml.Node
+}
+
+func (k yamlKey) value() string {
+	if k.node.ShortTag() == "!!null" {
+		return ""
+	}
+	return k.node.Value
+}
+
+// yamlKeys returns the string values below the mapping in file order.
+// Other scalars, e.g. numbers and booleans, and aliases are left out.
+func yamlKeys(m *yaml.Node, prefix []string) []yamlKey {
+	keys := make([]yamlKey, 0)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k, v := m.Content[i], m.Content[i+1]
+		if k.Value == "<<" {
+			continue
+		}
+		parts := append(append([]string{}, prefix...), k.Value)
+		path := yamlPath(parts)
+		switch v.Kind {
+		case yaml.MappingNode:
+			keys = append(keys, yamlKeys(v, parts)...)
+		case yaml.SequenceNode:
+			for ix, item := range v.Content {
+				if isYAMLString(item) {
+					itemParts := append(append([]string{}, prefix...), indexKey(k.Value, ix))
+					keys = append(keys, yamlKey{indexKey(path, ix), itemParts, item})
+				}
+			}
+		case yaml.ScalarNode:
+			if isYAMLString(v) {
+				keys = append(keys, yamlKey{path, parts, v})
+			}
+		}
+	}
+	return keys
+}
+
+func yamlPath(parts []string) string {
+	path := ""
+	for _, part := range parts {
+		path = joinKey(path, part)
+	}
+	return path
+}
+
+func isYAMLString(n *yaml.Node) bool {
+	if n.Kind != yaml.ScalarNode {
+		return false
+	}
+	tag := n.ShortTag()
+	return tag == "!!str" || tag == "!!null"
+}
+
+// setYAMLValue sets the value of the keys below the mapping, and creates the
+// missing mappings and sequences on the way
+func setYAMLValue(m *yaml.Node, parts []string, value string) {
+	node := m
+	for _, part := range parts[:len(parts)-1] {
+		child := yamlMappingGet(node, part)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			yamlMappingAppend(node, part, child)
+		}
+		if child.Kind != yaml.MappingNode {
+			return
+		}
+		node = child
+	}
+
+	last := parts[len(parts)-1]
+	if v := yamlMappingGet(node, last); v != nil {
+		if v.Kind == yaml.ScalarNode {
+			setYAMLScalar(v, value)
+		}
+		return
+	}
+
+	if name, ix, ok := splitIndexKey(last); ok {
+		seq := yamlMappingGet(node, name)
+		if seq == nil {
+			seq = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			yamlMappingAppend(node, name, seq)
+		}
+		if seq.Kind != yaml.SequenceNode {
+			return
+		}
+		for len(seq.Content) <= ix {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"})
+		}
+		setYAMLScalar(seq.Content[ix], value)
+		return
+	}
+
+	v := &yaml.Node{Kind: yaml.ScalarNode}
+	setYAMLScalar(v, value)
+	yamlMappingAdd(node, last, v)
+}
+
+func setYAMLScalar(n *yaml.Node, value string) {
+	if n.Value == value && n.ShortTag() == "!!str" {
+		return
+	}
+	n.Value = value
+	n.Tag = "!!str"
+	// the encoder adds quotes if the value needs them
+	n.Style &^= yaml.TaggedStyle
+}
+
+func yamlMappingGet(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func yamlMappingAppend(m *yaml.Node, key string, value *yaml.Node) {
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// yamlMappingAdd appends the entry, or adds it after the last form of its
+// text if it is a plural form, e.g. "files_few" after "files_other"
+func yamlMappingAdd(m *yaml.Node, key string, value *yaml.Node) {
+	keys := make([]string, 0, len(m.Content)/2)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		keys = append(keys, m.Content[i].Value)
+	}
+	after := lastPluralForm(keys, key)
+	if after < 0 {
+		yamlMappingAppend(m, key, value)
+		return
+	}
+	at := 2 * (after + 1)
+	entry := []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value}
+	m.Content = append(m.Content[:at], append(entry, m.Content[at:]...)...)
+}
+
+// yamlIndent returns the indentation of the first indented line
+func yamlIndent(buf []byte) int {
+	for _, line := range strings.Split(string(buf), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == line || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		return len(line) - len(trimmed)
+	}
+	return yamlDefaultIndent
+}
@@ -0,0 +1,136 @@
+package parser
+
+import "testing"
+
+func TestYAMLMarshalPluralForms(t *testing.T) {
+	source := `en:
+  title: Files
+  files_one: "{{count}} file"
+  files_other: "{{count}} files"
+  bye: Bye
+`
+	items := map[string]string{
+		"title":       "Файлы",
+		"files_one":   "{{count}} файл",
+		"files_few":   "{{count}} файла",
+		"files_many":  "{{count}} файлов",
+		"files_other": "{{count}} файла",
+		"bye":         "Пока",
+	}
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{
+			name:   "new file",
+			target: "",
+			want: `ru:
+  title: Файлы
+  files_one: '{{count}} файл'
+  files_other: '{{count}} файла'
+  files_few: '{{count}} файла'
+  files_many: '{{count}} файлов'
+  bye: Пока
+`,
+		},
+		{
+			name: "existing forms",
+			target: `ru:
+  title: Файлы
+  files_one: "{{count}} файл"
+  files_other: "{{count}} файла"
+  bye: Пока
+`,
+			want: `ru:
+  title: Файлы
+  files_one: "{{count}} файл"
+  files_other: "{{count}} файла"
+  files_few: '{{count}} файла'
+  files_many: '{{count}} файлов'
+  bye: Пока
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := parseContent(t, yamlFormat{}, "en", source, true)
+			target := parseContent(t, yamlFormat{}, "ru", tt.target, false)
+			target.Source = src
+			if got := marshalWith(t, target, items); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLParseRootKey(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		text string
+		want map[string]string
+	}{
+		{"language key", "ja", "ja:\n  home:\n    title: ホーム\n", map[string]string{"home/title": "ホーム"}},
+		{"regional language key", "pt-BR", "pt_BR:\n  title: Início\n", map[string]string{"title": "Início"}},
+		{"other language key", "en", "ja:\n  title: ホーム\n", map[string]string{"ja/title": "ホーム"}},
+		{"no language key", "en", "home:\n  title: Home\nbye: Bye\n", map[string]string{"home/title": "Home", "bye": "Bye"}},
+		{"sequence", "en", "en:\n  days:\n    - Mon\n    - Tue\n", map[string]string{"days[0]": "Mon", "days[1]": "Tue"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseContent(t, yamlFormat{}, tt.code, tt.text, false)
+			if len(l.LocaleItemsMap) != len(tt.want) {
+				t.Errorf("got items %q, want %q", l.LocaleItemsMap, tt.want)
+			}
+			for k, v := range tt.want {
+				if got := l.LocaleItemsMap.GetString(k); got != v {
+					t.Errorf("%q = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	text := `# Japanese
+ja:
+    home:
+        # shown at the top
+        title: ホーム
+        body: |
+            一行目
+            二行目
+    count: 3
+    bye: "さようなら"
+`
+	tests := []struct {
+		name  string
+		items map[string]string
+		want  string
+	}{
+		{"unchanged", nil, text},
+		{"nested value", map[string]string{"home/title": "トップ"}, replaceOnce(text, "title: ホーム", "title: トップ")},
+		{"quoted value", map[string]string{"bye": "またね"}, replaceOnce(text, `"さようなら"`, `"またね"`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := parseContent(t, yamlFormat{}, "ja", text, false)
+			if got := marshalWith(t, l, tt.items); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLMarshalNewTarget(t *testing.T) {
+	source := parseContent(t, yamlFormat{}, "en", "en:\n   home:\n      title: Home\n   bye: Bye\n", false)
+	target := parseContent(t, yamlFormat{}, "ja", "", false)
+	target.Source = source
+	got := marshalWith(t, target, map[string]string{"home/title": "ホーム", "bye": "さようなら"})
+	want := "ja:\n   home:\n      title: ホーム\n   bye: さようなら\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
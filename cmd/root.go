@@ -7,10 +7,12 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path"
-	"path/filepath"
+	"strings"
 
+	"github.com/quailyquaily/translate-cli/cmd/config"
+	"github.com/quailyquaily/translate-cli/cmd/parser"
 	"github.com/quailyquaily/translate-cli/cmd/translate"
+	"github.com/quailyquaily/translate-cli/cmd/xliff"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -19,13 +21,43 @@ var (
 	debugMode bool
 )
 
-var cfgFile string
+var (
+	cfgFile string
+	// configErr is the error of reading the config, the commands which use
+	// the config fail with it
+	configErr error
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "translate-cli",
 	Short: "Translate your locale files",
 	Long:  `Translate your locale files with AI`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if configErr != nil {
+			cmd.SilenceUsage = true
+			return configErr
+		}
+		if err := config.ApplyFlags(cmd); err != nil {
+			return err
+		}
+
+		sep := viper.GetString("key_separator")
+		if sep == "" || strings.Contains(sep, `\`) {
+			return fmt.Errorf("invalid key separator %q", sep)
+		}
+		parser.KeySeparator = sep
+		parser.FlatKeys = viper.GetBool("flat_keys")
+		parser.SourceLang = viper.GetString("source_lang")
+		parser.Targets = viper.GetStringSlice("targets")
+
+		layout := viper.GetString("layout")
+		if err := parser.CheckLayout(layout); err != nil {
+			return err
+		}
+		parser.Layout = layout
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -39,60 +71,28 @@ func ExecuteContext(ctx context.Context) {
 
 func init() {
 	rootCmd.AddCommand(translate.NewCmd())
+	rootCmd.AddCommand(translate.NewStatusCmd())
+	rootCmd.AddCommand(xliff.NewExportCmd())
+	rootCmd.AddCommand(xliff.NewImportCmd())
+	rootCmd.AddCommand(config.NewCmd())
 
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is $HOME/.translate-cli.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is $HOME/.config/translate-cli/config.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&debugMode, "debug", false, "toggle debug mode")
+	rootCmd.PersistentFlags().String("key-separator", "/", "the separator of nested keys")
+	rootCmd.PersistentFlags().Bool("flat-keys", false, "write new keys as they are, without nesting them")
+	rootCmd.PersistentFlags().String("source-lang", "", "the language code of the source file (default is the language of its file name, or en-US)")
+	rootCmd.PersistentFlags().String("layout", "", "the path pattern of the locale files in the directory, e.g. {lang}/{namespace}.json")
+	rootCmd.PersistentFlags().StringSlice("targets", nil, "the language codes to translate into, e.g. ja,zh-TW. missing files are created (default is all files in the directory)")
+
+	viper.BindPFlag("key_separator", rootCmd.PersistentFlags().Lookup("key-separator"))
+	viper.BindPFlag("flat_keys", rootCmd.PersistentFlags().Lookup("flat-keys"))
+	viper.BindPFlag("source_lang", rootCmd.PersistentFlags().Lookup("source-lang"))
+	viper.BindPFlag("targets", rootCmd.PersistentFlags().Lookup("targets"))
+	viper.BindPFlag("layout", rootCmd.PersistentFlags().Lookup("layout"))
 }
 
 func initConfig() {
-	if cfgFile != "" {
-		// Use config file from the flag
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-
-		fullpath := filepath.Join(home, ".config", "translate-cli")
-		if _, err := os.Stat(fullpath); os.IsNotExist(err) {
-			os.MkdirAll(fullpath, 0755)
-		}
-
-		viper.AddConfigPath(path.Join(home, ".config", "translate-cli"))
-		viper.SetConfigType("yaml")
-		viper.SetConfigName("config")
-
-		cfgFile = filepath.Join(fullpath, "config.yaml")
-
-		viper.SetConfigFile(cfgFile)
-
-		// if the config file is not found, create a new one
-		if _, err := os.Stat(viper.ConfigFileUsed()); os.IsNotExist(err) {
-			// create the config file
-			viper.Set("provider", "openai")
-			viper.Set("openai.api_key", "")
-			viper.Set("openai.api_base", "https://api.openai.com/v1")
-			viper.Set("openai.model", "")
-			viper.Set("debug", false)
-
-			os.MkdirAll(filepath.Dir(viper.ConfigFileUsed()), 0755)
-			err = viper.WriteConfigAs(path.Join(fullpath, "config.yaml"))
-			if err != nil {
-				fmt.Println("failed to save config", err, "config", fullpath)
-				return
-			}
-			fmt.Println("created config file:", fullpath)
-			fmt.Println("please edit the config file and run the command again")
-		}
-	}
-
-	// Read in environment variables that match
-	viper.AutomaticEnv()
-
-	// If a config file is found, read it in
-	if err := viper.ReadInConfig(); err != nil {
-		fmt.Println("failed to read config", err, "config", viper.ConfigFileUsed())
-		return
-	}
+	configErr = config.Load(cfgFile)
 }
@@ -0,0 +1,101 @@
+package translate
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/quailyquaily/translate-cli/internal/assistant"
+	"github.com/spf13/viper"
+)
+
+// printDryRun prints the batches which would be sent for each file, with the
+// size of their prompts and the estimated tokens and cost of the run with
+// each configured model
+func printDryRun(out io.Writer, ant *assistant.Assistant, jobs []*fileJob, section string) {
+	keys, requests, promptTokens, outputTokens := 0, 0, 0, 0
+	for _, job := range jobs {
+		if len(job.inputs) == 0 {
+			fmt.Fprintf(out, "✅ %s: nothing to translate\n", job.target.Path)
+			continue
+		}
+		fmt.Fprintf(out, "📝 %s: %d keys, %d requests\n", job.target.Path, job.size, len(job.inputs))
+		for ix, input := range job.inputs {
+			// the prompt translateItems sends, e.g. a single text for a batch of one
+			prompt := ant.Prompt(input)
+			_, output := input.EstimateUsage()
+			promptSize := assistant.EstimateTokens(prompt)
+			inputKeys := []string{input.Key}
+			if input.ContentItems != nil {
+				inputKeys = make([]string, 0, len(input.ContentItems))
+				for k := range input.ContentItems {
+					inputKeys = append(inputKeys, k)
+				}
+				sort.Strings(inputKeys)
+			}
+			fmt.Fprintf(out, "  - request %d: %d keys, prompt: %d chars, ~%d tokens in, ~%d tokens out\n",
+				ix+1, len(inputKeys), len([]rune(prompt)), promptSize, output)
+			fmt.Fprintf(out, "    %s\n", strings.Join(inputKeys, ", "))
+
+			promptTokens += promptSize
+			outputTokens += output
+		}
+		keys += job.size
+		requests += len(job.inputs)
+	}
+
+	fmt.Fprintf(out, "🧮 total: %d keys, %d requests, ~%d tokens in, ~%d tokens out\n", keys, requests, promptTokens, outputTokens)
+	defer fmt.Fprintln(out, "nothing was sent or written. run the command without --dry-run to translate.")
+
+	sections := modelSections(section)
+	if len(sections) == 0 {
+		if section == "" {
+			section = "openai"
+		}
+		fmt.Fprintf(out, "💰 no model is configured. set %s.model to estimate the cost.\n", section)
+		return
+	}
+	for _, name := range sections {
+		model := viper.GetString(name + ".model")
+		label := model
+		if name == section {
+			label += " (in use)"
+		} else {
+			label += " (" + name + ")"
+		}
+
+		price, ok := assistant.PriceOf(model)
+		if viper.IsSet(name+".input_price") || viper.IsSet(name+".output_price") {
+			price = assistant.Price{
+				Input:  viper.GetFloat64(name + ".input_price"),
+				Output: viper.GetFloat64(name + ".output_price"),
+			}
+			ok = true
+		}
+		if !ok {
+			fmt.Fprintf(out, "💰 the price of %s is unknown. set %s.input_price and %s.output_price to estimate the cost.\n", label, name, name)
+			continue
+		}
+		fmt.Fprintf(out, "💰 estimated cost with %s: $%.4f\n", label, price.Cost(promptTokens, outputTokens))
+	}
+}
+
+// modelSections returns the config sections which set a model, e.g. "openai"
+// and "azure", with the section in use first
+func modelSections(inUse string) []string {
+	sections := make([]string, 0)
+	for name, v := range viper.AllSettings() {
+		if _, ok := v.(map[string]interface{}); !ok || name == inUse || name == "languages" {
+			continue
+		}
+		if viper.GetString(name+".model") != "" {
+			sections = append(sections, name)
+		}
+	}
+	sort.Strings(sections)
+	if viper.GetString(inUse+".model") != "" {
+		sections = append([]string{inUse}, sections...)
+	}
+	return sections
+}
@@ -0,0 +1,56 @@
+package translate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// languageKey returns the config key of a setting which is overridden for
+// the target language, e.g. "languages.ja.batch", or "" if it is not
+func languageKey(code, setting string) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", "-"))
+	}
+	for name := range viper.GetStringMap("languages") {
+		key := "languages." + name + "." + setting
+		if normalize(name) == normalize(code) && viper.IsSet(key) {
+			return key
+		}
+	}
+	return ""
+}
+
+// languageBatchSize returns the batch size of the target language
+func languageBatchSize(code string) int {
+	if key := languageKey(code, "batch"); key != "" {
+		return viper.GetInt(key)
+	}
+	return batchSize
+}
+
+// languageBackgrounds reads the backgrounds which are overridden per target
+// language, by language code. The others use the background of the run.
+func languageBackgrounds(codes []string, background string) map[string]string {
+	backgrounds := make(map[string]string)
+	for _, code := range codes {
+		if _, ok := backgrounds[code]; ok {
+			continue
+		}
+		backgrounds[code] = background
+
+		key := languageKey(code, "background")
+		if key == "" {
+			continue
+		}
+		buf, err := os.ReadFile(viper.GetString(key))
+		if err != nil {
+			fmt.Printf("read background file of %s failed: %s. Use the default background.\n", code, err)
+			continue
+		}
+		backgrounds[code] = string(buf)
+	}
+	return backgrounds
+}
@@ -0,0 +1,88 @@
+package translate
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// progress prints one status line for all files. Other lines are printed
+// above it, so the output stays readable when files are translated in parallel.
+type progress struct {
+	mu  sync.Mutex
+	out io.Writer
+
+	keys      int
+	keysDone  int
+	files     int
+	filesDone int
+
+	// width is the length of the status line on the screen
+	width int
+}
+
+func newProgress(out io.Writer, files, keys int) *progress {
+	return &progress{
+		out:   out,
+		files: files,
+		keys:  keys,
+	}
+}
+
+// add counts the translated keys
+func (p *progress) add(keys int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keysDone += keys
+	p.render()
+}
+
+// fileDone counts a finished file and prints its line
+func (p *progress) fileDone(format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.filesDone += 1
+	p.printLine(fmt.Sprintf(format, args...))
+}
+
+// println prints a line above the status line
+func (p *progress) println(format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.printLine(fmt.Sprintf(format, args...))
+}
+
+func (p *progress) printLine(line string) {
+	p.clear()
+	fmt.Fprintln(p.out, line)
+	p.width = 0
+	if p.filesDone < p.files {
+		p.render()
+	}
+}
+
+func (p *progress) render() {
+	status := fmt.Sprintf("🔄 keys: %d/%d, files: %d/%d", p.keysDone, p.keys, p.filesDone, p.files)
+	p.clear()
+	fmt.Fprint(p.out, status)
+	p.width = len([]rune(status)) + 1
+}
+
+func (p *progress) clear() {
+	if p.width > 0 {
+		fmt.Fprint(p.out, "\r"+strings.Repeat(" ", p.width)+"\r")
+	}
+}
+
+// done ends the status line
+func (p *progress) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	p.width = 0
+}
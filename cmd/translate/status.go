@@ -0,0 +1,81 @@
+package translate
+
+import (
+	"sort"
+
+	"github.com/quailyquaily/translate-cli/cmd/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusDir        string
+	statusSourceFile string
+)
+
+func NewStatusCmd() *cobra.Command {
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "List the keys which need a translation or are not in the source",
+		Run: func(cmd *cobra.Command, args []string) {
+			sources, others, err := findFiles(statusSourceFile, statusDir, false)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			lock, err := lockfile.Load(lockfile.PathFor(sources[0]))
+			if err != nil {
+				cmd.PrintErrln("read lock file failed: ", err)
+				return
+			}
+
+			pending, orphaned := 0, 0
+			for _, target := range others {
+				reasons := make(map[string]string)
+				keys := make([]string, 0)
+				for k, v := range target.Source.SourceItemsFor(target) {
+					text := v.(string)
+					if text == "" {
+						continue
+					}
+					if reason := translateReason(target, lock, k, text); reason != "" {
+						reasons[k] = reason
+						keys = append(keys, k)
+					}
+				}
+				sort.Strings(keys)
+				orphans := target.Source.OrphanKeys(target)
+
+				if len(keys) == 0 && len(orphans) == 0 {
+					cmd.Printf("✅ %s: up to date\n", target.Path)
+					continue
+				}
+				if target.IsNew() {
+					cmd.Printf("🆕 %s: new file, %d keys\n", target.Path, len(keys))
+				} else {
+					cmd.Printf("🔄 %s: %d keys\n", target.Path, len(keys)+len(orphans))
+				}
+				for _, k := range keys {
+					cmd.Printf("  - %s (%s)\n", k, reasons[k])
+				}
+				for _, k := range orphans {
+					cmd.Printf("  - %s (not in the source)\n", k)
+				}
+				pending += len(keys)
+				orphaned += len(orphans)
+			}
+
+			if pending > 0 {
+				cmd.Printf("%d keys need a translation. run the translate command to translate them.\n", pending)
+			}
+			if orphaned > 0 {
+				cmd.Printf("%d keys are not in the source. run the translate command with --prune to remove them.\n", orphaned)
+			}
+		},
+	}
+
+	statusCmd.Flags().StringVarP(&statusDir, "dir", "d", "", "the directory of language files")
+	statusCmd.Flags().StringVarP(&statusSourceFile, "source", "s", "", "the source language file")
+
+	return statusCmd
+}
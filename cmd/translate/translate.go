@@ -2,21 +2,24 @@ package translate
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path"
-	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/lyricat/goutils/ai"
 	"github.com/lyricat/goutils/structs"
+	"github.com/quailyquaily/translate-cli/cmd/config"
+	"github.com/quailyquaily/translate-cli/cmd/lockfile"
 	"github.com/quailyquaily/translate-cli/cmd/parser"
 	"github.com/quailyquaily/translate-cli/internal/assistant"
+	"github.com/quailyquaily/translate-cli/internal/placeholder"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"golang.org/x/text/language"
-	"golang.org/x/text/language/display"
 )
 
 var (
@@ -25,27 +28,64 @@ var (
 	glossaryFile   string
 	backgroundFile string
 	batchSize      int
+	sortKeys       bool
+	repair         bool
+	concurrency    int
+	prune          bool
+	dryRun         bool
+	mask           bool
 )
 
 func NewCmd() *cobra.Command {
 	translateCmd := &cobra.Command{
 		Use: "translate",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			// a dry run sends nothing, so it works without credentials
+			if dryRun {
+				return nil
+			}
+			cmd.SilenceUsage = true
+			return config.CheckProvider()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := context.Background()
 
+			provider := viper.GetString("provider")
 			aiInst := ai.New(ai.Config{
-				Provider:      viper.GetString("provider"),
-				OpenAIAPIKey:  viper.GetString("openai.api_key"),
-				OpenAIAPIBase: viper.GetString("openai.api_base"),
-				OpenAIModel:   viper.GetString("openai.model"),
-				Debug:         viper.GetBool("debug"),
+				Provider:       provider,
+				OpenAIAPIKey:   viper.GetString("openai.api_key"),
+				OpenAIAPIBase:  viper.GetString("openai.api_base"),
+				OpenAIModel:    viper.GetString("openai.model"),
+				SusanooAPIKey:  viper.GetString("susanoo.api_key"),
+				SusanooAPIBase: viper.GetString("susanoo.api_base"),
+				Debug:          viper.GetBool("debug"),
 			})
+			if aiInst == nil && !dryRun {
+				cmd.PrintErrf("failed to create the client of the provider %s. check the config with `translate-cli config validate`\n", provider)
+				return
+			}
+
+			var masker *placeholder.Masker
+			if mask || viper.GetBool("mask.enabled") {
+				var err error
+				masker, err = placeholder.NewMasker(viper.GetStringSlice("mask.rules"), viper.GetStringSlice("mask.patterns"))
+				if err != nil {
+					cmd.PrintErrln(err)
+					return
+				}
+			}
 
+			viper.SetDefault("max_retries", assistant.DefaultMaxRetries)
+			section := assistant.Providers[provider].Section
 			ant := assistant.New(assistant.Config{
-				Provider: viper.GetString("provider"),
+				Provider:   provider,
+				MaxRetries: viper.GetInt("max_retries"),
+				RPM:        viper.GetInt(section + ".rpm"),
+				TPM:        viper.GetInt(section + ".tpm"),
+				Mask:       masker,
 			}, aiInst)
 
-			source, others, glossary, background, err := provideFiles()
+			sources, others, glossary, background, err := provideFiles()
 			if err != nil {
 				cmd.PrintErrln(err)
 				return
@@ -53,7 +93,14 @@ func NewCmd() *cobra.Command {
 			if batchSize > 1 {
 				cmd.Println("📦 batch size:", batchSize)
 			}
-			cmd.Printf("📄 source:\n  - file: %s\n  - records: %d\n", source.Path, len(source.LocaleItemsMap))
+			if len(sources) == 1 {
+				cmd.Printf("📄 source:\n  - file: %s\n  - records: %d\n", sources[0].Path, len(sources[0].LocaleItemsMap))
+			} else {
+				cmd.Println("📄 sources:")
+				for _, source := range sources {
+					cmd.Printf("  - file: %s, records: %d\n", source.Path, len(source.LocaleItemsMap))
+				}
+			}
 
 			if glossary != nil {
 				cmd.Printf("📖 glossary:\n  - file: %s\n", glossaryFile)
@@ -63,14 +110,61 @@ func NewCmd() *cobra.Command {
 				cmd.Printf("📚 background:\n  - file: %s\n", backgroundFile)
 			}
 
-			cmd.Println("🌍 translating ...")
+			// the sources of a layout share the lock file
+			lock, err := lockfile.Load(lockfile.PathFor(sources[0]))
+			if err != nil {
+				cmd.PrintErrln("read lock file failed: ", err)
+				return
+			}
+
 			for _, item := range others {
-				err = process(ctx, ant, source, item, glossary, background)
-				if err != nil {
-					cmd.PrintErrln("process failed: ", err)
-					return
+				if item.IsNew() {
+					cmd.Printf("🆕 %s: new file\n", item.Path)
 				}
 			}
+
+			for _, item := range others {
+				orphans := item.Source.OrphanKeys(item)
+				if len(orphans) == 0 {
+					continue
+				}
+				if !prune || dryRun {
+					cmd.Printf("⚠️  %s: %d keys are not in the source: %s. use --prune to remove them.\n",
+						item.Path, len(orphans), strings.Join(orphans, ", "))
+					continue
+				}
+				if err := item.Remove(orphans); err != nil {
+					cmd.Printf("⚠️  %s. skip pruning this file.\n", err)
+					continue
+				}
+				for _, key := range orphans {
+					lock.Delete(item, key)
+				}
+				cmd.Printf("🗑️  %s: removed %d keys which are not in the source\n", item.Path, len(orphans))
+			}
+
+			codes := make([]string, 0, len(others))
+			for _, item := range others {
+				codes = append(codes, item.Code)
+			}
+			backgrounds := languageBackgrounds(codes, background)
+
+			jobs := make([]*fileJob, 0, len(others))
+			for _, item := range others {
+				jobs = append(jobs, newFileJob(item.Source, item, lock, glossary, backgrounds[item.Code]))
+			}
+
+			if dryRun {
+				printDryRun(cmd.OutOrStdout(), ant, jobs, section)
+				return
+			}
+
+			cmd.Println("🌍 translating ...")
+			if err := translateAll(ctx, ant, jobs, lock, cmd.OutOrStdout()); err != nil {
+				cmd.PrintErrln("process failed: ", err)
+				cmd.PrintErrln("the finished batches are saved. run the command again to translate the rest.")
+				return
+			}
 		},
 	}
 
@@ -79,122 +173,303 @@ func NewCmd() *cobra.Command {
 	translateCmd.Flags().StringVarP(&glossaryFile, "glossary", "g", "", "the glossary file")
 	translateCmd.Flags().StringVarP(&backgroundFile, "background", "b", "", "the background file")
 	translateCmd.Flags().IntVar(&batchSize, "batch", 5, "the batch size")
+	translateCmd.Flags().IntVar(&concurrency, "concurrency", 1, "the number of batches translated at the same time")
+	translateCmd.Flags().BoolVar(&sortKeys, "sort-keys", false, "write the keys of JSON files in alphabetical order")
+	translateCmd.Flags().BoolVar(&prune, "prune", false, "remove the keys which are not in the source from the target files")
+	translateCmd.Flags().BoolVar(&mask, "mask", false, "replace placeholders, HTML tags and link targets with tokens before sending the text to the AI provider")
+	translateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the batches and the estimated tokens and cost without translating or writing anything")
+	translateCmd.Flags().BoolVar(&repair, "repair", false, "back up malformed target files and rewrite them")
 
 	return translateCmd
 }
 
-func process(ctx context.Context, ant *assistant.Assistant,
-	source *parser.LocaleFileContent, target *parser.LocaleFileContent, glossary *parser.GlossaryContent, background string) error {
+type (
+	// fileJob is the translation of one target file
+	fileJob struct {
+		target *parser.LocaleFileContent
+		inputs []*assistant.TranslateInput
+		// total is the number of source items, size is the number of items to translate
+		total int
+		size  int
+
+		mu      sync.Mutex
+		count   int
+		pending int
+	}
 
-	groupResult := []*assistant.TranslateInput{}
+	task struct {
+		job   *fileJob
+		input *assistant.TranslateInput
+	}
+)
 
+// newFileJob collects the items of the target which need a translation and
+// splits them into batches
+func newFileJob(source, target *parser.LocaleFileContent, lock *lockfile.Lock, glossary *parser.GlossaryContent, background string) *fileJob {
 	itemsNeedToTranslate := structs.NewJSONMap()
 
-	for k, _v := range source.LocaleItemsMap {
-		needToTranslate := false
+	sourceItems := source.SourceItemsFor(target)
+	for k, _v := range sourceItems {
 		v := _v.(string)
-		if v != "" {
-			if _, ok := target.LocaleItemsMap[k]; !ok {
-				// key does not exist, translate it
-				needToTranslate = true
-			} else {
-				// key exists
-				if target.LocaleItemsMap.GetString(k) == "" {
-					// empty string, translate it
-					needToTranslate = true
-				} else if target.LocaleItemsMap.GetString(k)[0] == '!' {
-					// value starts with "!", translate it
-					needToTranslate = true
-				}
-			}
-			if needToTranslate {
-				itemsNeedToTranslate.SetValue(k, v)
-			}
+		if v == "" {
+			continue
+		}
+		if reason := translateReason(target, lock, k, v); reason != "" {
+			itemsNeedToTranslate.SetValue(k, v)
+		} else {
+			// remember the source of existing values, so later changes are found
+			lock.Track(target, k, v)
 		}
 	}
 
-	needToTranslateSize := itemsNeedToTranslate.Size()
+	job := &fileJob{
+		target: target,
+		total:  len(sourceItems),
+		size:   itemsNeedToTranslate.Size(),
+	}
 
-	var splitItems []structs.JSONMap
-	if batchSize > 1 && needToTranslateSize > batchSize {
-		splitItems = itemsNeedToTranslate.Split(batchSize)
-	} else {
-		splitItems = []structs.JSONMap{itemsNeedToTranslate}
+	size := languageBatchSize(target.Code)
+	for _, item := range splitBatches(itemsNeedToTranslate, size) {
+		// the plural forms of one text are sent together even without batches
+		if size > 1 || len(item) > 1 {
+			job.inputs = append(job.inputs, &assistant.TranslateInput{
+				ContentItems: item,
+				Lang:         target.Lang,
+				LangCode:     target.Code,
+				SourceLang:   source.Lang,
+				Background:   background,
+				Glossary:     glossary.GetMapByLang(target.Code),
+			})
+			continue
+		}
+		for k := range item {
+			job.inputs = append(job.inputs, &assistant.TranslateInput{
+				Key:        k,
+				Content:    item.GetString(k),
+				Lang:       target.Lang,
+				LangCode:   target.Code,
+				SourceLang: source.Lang,
+				Background: background,
+				Glossary:   glossary.GetMapByLang(target.Code),
+			})
+		}
 	}
+	job.pending = len(job.inputs)
 
-	contentItems := structs.JSONMap{}
-	for _, item := range splitItems {
-		for k, v := range item {
-			// translate each group
-			if batchSize > 1 {
-				contentItems.SetValue(k, v)
-			} else {
-				groupResult = append(groupResult, &assistant.TranslateInput{
-					Key:        k,
-					Content:    item.GetString(k),
-					Lang:       target.Lang,
-					LangCode:   target.Code,
-					Background: background,
-					Glossary:   glossary.GetMapByLang(target.Code),
-				})
+	return job
+}
+
+// splitBatches splits the items into batches of up to size items in key
+// order. The plural forms of one text, e.g. "files_one" and "files_other",
+// and the items of one array stay in one batch, which may make it larger.
+func splitBatches(items structs.JSONMap, size int) []structs.JSONMap {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	groups := make([][]string, 0, len(keys))
+	groupIx := make(map[string]int)
+	for _, k := range keys {
+		if base, ok := parser.KeyGroup(k); ok {
+			if ix, ok := groupIx[base]; ok {
+				groups[ix] = append(groups[ix], k)
+				continue
 			}
+			groupIx[base] = len(groups)
 		}
+		groups = append(groups, []string{k})
+	}
 
-		if batchSize > 1 {
-			if len(contentItems) > 0 {
-				groupResult = append(groupResult, &assistant.TranslateInput{
-					ContentItems: contentItems,
-					Lang:         target.Lang,
-					LangCode:     target.Code,
-					Background:   background,
-					Glossary:     glossary.GetMapByLang(target.Code),
-				})
-			}
+	batches := make([]structs.JSONMap, 0)
+	var batch structs.JSONMap
+	for _, group := range groups {
+		if batch != nil && len(batch)+len(group) > size {
+			batches = append(batches, batch)
+			batch = nil
+		}
+		if batch == nil {
+			batch = structs.NewJSONMap()
 		}
+		for _, k := range group {
+			batch[k] = items[k]
+		}
+	}
+	if batch != nil {
+		batches = append(batches, batch)
 	}
+	return batches
+}
 
-	count := 0
-	if batchSize > 1 {
-		for _, item := range groupResult {
-			ret, err := ant.TranslateBatch(ctx, item)
-			if err != nil {
-				return err
+// translateReason tells why the key of the target needs a translation, or
+// returns "" if the value is up to date
+func translateReason(target *parser.LocaleFileContent, lock *lockfile.Lock, key, sourceText string) string {
+	if _, ok := target.LocaleItemsMap[key]; !ok {
+		return "missing"
+	}
+	value := target.LocaleItemsMap.GetString(key)
+	if value == "" {
+		return "empty"
+	}
+	if value[0] == '!' {
+		return "marked with !"
+	}
+	if lock.SourceChanged(target, key, sourceText) {
+		return "source changed"
+	}
+	return ""
+}
+
+// translateAll runs the batches of all files with a pool of workers. Each file
+// is written as soon as all of its batches are translated. The first error
+// stops the pool.
+func translateAll(ctx context.Context, ant *assistant.Assistant, jobs []*fileJob, lock *lockfile.Lock, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := 0
+	for _, job := range jobs {
+		keys += job.size
+	}
+	p := newProgress(out, len(jobs), keys)
+	defer p.done()
+
+	ant.OnWarning(func(msg string) {
+		p.println("⚠️  %s", msg)
+	})
+	defer ant.OnWarning(nil)
+
+	var (
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	workers := concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	tasks := make(chan *task)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := runTask(ctx, ant, t, lock, p); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		if len(job.inputs) == 0 {
+			if err := saveJob(job, lock); err != nil {
+				fail(err)
 			}
-			for k, v := range ret {
-				target.LocaleItemsMap.SetValue(k, v)
-				count += 1
+			reportJob(job, p)
+			continue
+		}
+		for _, input := range job.inputs {
+			select {
+			case tasks <- &task{job: job, input: input}:
+			case <-ctx.Done():
 			}
-			fmt.Printf("\r🔄 %s: %d/%d", target.Path, count, needToTranslateSize)
 		}
+	}
+	close(tasks)
+	wg.Wait()
+
+	return firstErr
+}
+
+func runTask(ctx context.Context, ant *assistant.Assistant, t *task, lock *lockfile.Lock, p *progress) error {
+	var (
+		ret structs.JSONMap
+		err error
+	)
+	if t.input.ContentItems != nil {
+		// a failed batch may still have translated some of the items
+		ret, err = ant.TranslateBatch(ctx, t.input)
 	} else {
-		for _, item := range groupResult {
-			result, err := ant.Translate(ctx, item)
-			if err != nil {
-				return err
-			}
-			target.LocaleItemsMap.SetValue(item.Key, result)
-			count += 1
-			fmt.Printf("\r🔄 %s: %d/%d", target.Path, count, needToTranslateSize)
+		var result string
+		result, err = ant.Translate(ctx, t.input)
+		if err == nil {
+			ret = structs.JSONMap{t.input.Key: result}
+		} else if errors.Is(err, assistant.ErrRejected) {
+			// the key stays untranslated, the next run asks again
+			p.println("⚠️  skip the key %q: %s", t.input.Key, err)
+			ret, err = structs.NewJSONMap(), nil
 		}
 	}
+	if err != nil && len(ret) == 0 {
+		return fmt.Errorf("%s: %w", t.job.target.Path, err)
+	}
 
-	buf, err := target.JSON()
-	if err != nil {
-		return err
+	// the file is written after each batch, so a failed run keeps the finished
+	// batches and the next run only translates the missing keys
+	job := t.job
+	job.mu.Lock()
+	for k, v := range ret {
+		job.target.LocaleItemsMap.SetValue(k, v)
+		lock.Set(job.target, k, &lockfile.Entry{Machine: true, Source: lockfile.Hash(sourceText(t.input, k))})
+	}
+	job.count += len(ret)
+	finished := false
+	if err == nil {
+		job.pending -= 1
+		finished = job.pending == 0
+	}
+	saveErr := saveJob(job, lock)
+	job.mu.Unlock()
+	if saveErr != nil {
+		return saveErr
 	}
 
-	err = os.WriteFile(target.Path, buf, 0644)
+	p.add(len(ret))
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: %w", t.job.target.Path, err)
+	}
+	if finished {
+		reportJob(job, p)
+	}
+	return nil
+}
+
+// sourceText returns the source text of a key in the input
+func sourceText(input *assistant.TranslateInput, key string) string {
+	if input.ContentItems != nil {
+		return input.ContentItems.GetString(key)
 	}
+	return input.Content
+}
 
-	fmt.Printf("\r✅ %s: %d/%d, total: %d, ignore: %d\n",
-		target.Path, count, needToTranslateSize, len(source.LocaleItemsMap), len(source.LocaleItemsMap)-needToTranslateSize)
+func saveJob(job *fileJob, lock *lockfile.Lock) error {
+	if err := job.target.Save(); err != nil {
+		return err
+	}
+	return lock.Save()
+}
 
-	return nil
+func reportJob(job *fileJob, p *progress) {
+	p.fileDone("✅ %s: %d/%d, total: %d, ignore: %d",
+		job.target.Path, job.count, job.size, job.total, job.total-job.size)
 }
 
-func provideFiles() (source *parser.LocaleFileContent, others []*parser.LocaleFileContent, glossary *parser.GlossaryContent, background string, err error) {
+func provideFiles() (sources []*parser.LocaleFileContent, others []*parser.LocaleFileContent, glossary *parser.GlossaryContent, background string, err error) {
 	if glossaryFile != "" {
 		glossary, err = parser.NewGlossaryFromJSONFile(glossaryFile)
 		if err != nil {
@@ -215,62 +490,44 @@ func provideFiles() (source *parser.LocaleFileContent, others []*parser.LocaleFi
 		}
 	}
 
-	if sourceFile != "" {
-		source = &parser.LocaleFileContent{}
-		if err = source.ParseFromJSONFile(sourceFile); err != nil {
-			return
-		}
-
-		var lang string
-		lang, err = langCodeToName("en-US")
-		if err != nil {
-			return
-		}
-
-		source.Code = "en-US"
-		source.Lang = lang
-	} else {
-		err = fmt.Errorf("source file is required. use -s flag to specify the source file")
+	// a dry run does not back up or rewrite malformed files
+	if sources, others, err = findFiles(sourceFile, dir, repair && !dryRun); err != nil {
 		return
 	}
-
-	if dir != "" {
-		others = make([]*parser.LocaleFileContent, 0)
-		items, _ := os.ReadDir(dir)
-		sourceBaseFile := filepath.Base(sourceFile)
-		for _, item := range items {
-			if !item.IsDir() {
-				name := filepath.Base(item.Name())
-				ext := filepath.Ext(name)
-				if strings.EqualFold(item.Name(), sourceBaseFile) {
-					continue
-				}
-
-				if strings.ToLower(ext) != ".json" {
-					fmt.Printf("file %s is not a JSON file. skip this file.\n", name)
-					continue
-				}
-
-				localeContent := &parser.LocaleFileContent{}
-				if err = localeContent.ParseFromJSONFile(path.Join(dir, item.Name())); err != nil {
-					fmt.Printf("parse file failed: %s. Use default locale content.\n", err)
-				}
-
-				others = append(others, localeContent)
-			}
-		}
-	} else {
-		err = fmt.Errorf("dir is required. use -d flag to specify the directory of language files")
-		return
+	for _, target := range others {
+		target.SortKeys = sortKeys
 	}
 
 	return
 }
 
-func langCodeToName(code string) (string, error) {
-	tag, err := language.Parse(code)
-	if err != nil {
-		return "", err
+// findFiles parses the source file and finds its targets in dir. With a
+// layout and without a source file, the sources are the files of all
+// namespaces in the source language. Each target keeps its source in Source.
+func findFiles(sourceFile, dir string, repair bool) (sources []*parser.LocaleFileContent, others []*parser.LocaleFileContent, err error) {
+	switch {
+	case sourceFile != "":
+		source, err := parser.NewSourceFromFile(sourceFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, source)
+	case parser.Layout != "":
+		if dir == "" {
+			return nil, nil, fmt.Errorf("dir is required. use -d flag to specify the directory of the layout")
+		}
+		if sources, err = parser.NewSourcesFromLayout(dir); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("source file is required. use -s flag to specify the source file")
+	}
+
+	for _, source := range sources {
+		if dir == "" && !source.IsMultiLang() {
+			return nil, nil, fmt.Errorf("dir is required. use -d flag to specify the directory of language files")
+		}
+		others = append(others, source.FindTargets(dir, repair)...)
 	}
-	return display.Self.Name(tag), nil
+	return sources, others, nil
 }
@@ -0,0 +1,93 @@
+package xliff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/quailyquaily/translate-cli/cmd/lockfile"
+	"github.com/quailyquaily/translate-cli/cmd/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportDir        string
+	exportSourceFile string
+	exportOutputDir  string
+	exportVersion    string
+)
+
+func NewExportCmd() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the locale files to XLIFF for review",
+		Run: func(cmd *cobra.Command, args []string) {
+			source, others, err := loadFiles(exportSourceFile, exportDir)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			lock, err := lockfile.Load(lockfile.PathFor(source))
+			if err != nil {
+				cmd.PrintErrln("read lock file failed: ", err)
+				return
+			}
+
+			if err := os.MkdirAll(exportOutputDir, 0755); err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			for _, target := range others {
+				doc := NewDocument(source, target, lock)
+				buf, err := doc.Marshal(exportVersion)
+				if err != nil {
+					cmd.PrintErrln("export failed: ", err)
+					return
+				}
+
+				name := target.Code + ".xlf"
+				if target.Namespace != "" {
+					// the namespaces of a layout, e.g. "common.ja.xlf"
+					name = target.Namespace + "." + name
+				}
+				out := filepath.Join(exportOutputDir, name)
+				if err := os.WriteFile(out, buf, 0644); err != nil {
+					cmd.PrintErrln("export failed: ", err)
+					return
+				}
+
+				review := 0
+				for _, u := range doc.Units {
+					if u.State == StateNeedsReview {
+						review += 1
+					}
+				}
+				cmd.Printf("✅ %s: %s, units: %d, needs review: %d\n", target.Path, out, len(doc.Units), review)
+			}
+		},
+	}
+
+	exportCmd.Flags().StringVarP(&exportDir, "dir", "d", "", "the directory of language files")
+	exportCmd.Flags().StringVarP(&exportSourceFile, "source", "s", "", "the source language file")
+	exportCmd.Flags().StringVarP(&exportOutputDir, "output", "o", "xliff", "the directory to write the XLIFF files to")
+	exportCmd.Flags().StringVar(&exportVersion, "xliff-version", Version12, "the XLIFF version, 1.2 or 2.0")
+
+	return exportCmd
+}
+
+func loadFiles(sourceFile, dir string) (*parser.LocaleFileContent, []*parser.LocaleFileContent, error) {
+	if sourceFile == "" {
+		return nil, nil, fmt.Errorf("source file is required. use -s flag to specify the source file")
+	}
+	source, err := parser.NewSourceFromFile(sourceFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if dir == "" && !source.IsMultiLang() {
+		return nil, nil, fmt.Errorf("dir is required. use -d flag to specify the directory of language files")
+	}
+	return source, source.FindTargets(dir, false), nil
+}
@@ -0,0 +1,97 @@
+package xliff
+
+import (
+	"os"
+
+	"github.com/quailyquaily/translate-cli/cmd/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importDir        string
+	importSourceFile string
+)
+
+func NewImportCmd() *cobra.Command {
+	importCmd := &cobra.Command{
+		Use:   "import [xliff files]",
+		Short: "Merge reviewed XLIFF files back into the locale files",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			source, others, err := loadFiles(importSourceFile, importDir)
+			if err != nil {
+				cmd.PrintErrln(err)
+				return
+			}
+
+			lock, err := lockfile.Load(lockfile.PathFor(source))
+			if err != nil {
+				cmd.PrintErrln("read lock file failed: ", err)
+				return
+			}
+
+			for _, file := range args {
+				buf, err := os.ReadFile(file)
+				if err != nil {
+					cmd.PrintErrln(err)
+					return
+				}
+				docs, err := Unmarshal(buf)
+				if err != nil {
+					cmd.PrintErrf("parse %s failed: %s\n", file, err)
+					return
+				}
+
+				for _, doc := range docs {
+					// match the target by the original path first, then by the language
+					var targetIx = -1
+					for ix, target := range others {
+						if target.Code == doc.TargetLang && (target.Path == doc.Original || targetIx < 0) {
+							targetIx = ix
+						}
+					}
+					if targetIx < 0 {
+						cmd.Printf("no locale file for %s in %s. skip it.\n", doc.TargetLang, file)
+						continue
+					}
+					target := others[targetIx]
+
+					sourceItems := source.SourceItemsFor(target)
+					count, reviewed := 0, 0
+					for _, u := range doc.Units {
+						if u.Target == "" {
+							continue
+						}
+						if _, ok := sourceItems[u.Key]; !ok {
+							cmd.Printf("key %s is not in the source. skip it.\n", u.Key)
+							continue
+						}
+						// an edited translation is the reviewer's, even if the state is not changed
+						machine := u.State == StateNeedsReview && u.Target == target.LocaleItemsMap.GetString(u.Key)
+						target.LocaleItemsMap.SetValue(u.Key, u.Target)
+						lock.Set(target, u.Key, &lockfile.Entry{Machine: machine, Source: lockfile.Hash(u.Source)})
+						if !machine {
+							reviewed += 1
+						}
+						count += 1
+					}
+
+					if err := target.Save(); err != nil {
+						cmd.PrintErrln("import failed: ", err)
+						return
+					}
+					cmd.Printf("✅ %s: %s, units: %d, reviewed: %d\n", file, target.Path, count, reviewed)
+				}
+			}
+
+			if err := lock.Save(); err != nil {
+				cmd.PrintErrln("write lock file failed: ", err)
+			}
+		},
+	}
+
+	importCmd.Flags().StringVarP(&importDir, "dir", "d", "", "the directory of language files")
+	importCmd.Flags().StringVarP(&importSourceFile, "source", "s", "", "the source language file")
+
+	return importCmd
+}
@@ -0,0 +1,318 @@
+package xliff
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/quailyquaily/translate-cli/cmd/lockfile"
+	"github.com/quailyquaily/translate-cli/cmd/parser"
+)
+
+// States of the translation units. The values of XLIFF 1.2 are used,
+// XLIFF 2.0 states are converted from and to them.
+const (
+	StateNew         = "new"
+	StateTranslated  = "translated"
+	StateNeedsReview = "needs-review-translation"
+	StateFinal       = "final"
+	StateSignedOff   = "signed-off"
+)
+
+const (
+	Version12 = "1.2"
+	Version20 = "2.0"
+
+	// subState20NeedsReview marks machine translated segments in XLIFF 2.0,
+	// which has no state for them
+	subState20NeedsReview = "translate-cli:" + StateNeedsReview
+)
+
+type (
+	// Document is one source and target file pair.
+	Document struct {
+		Original   string
+		SourceLang string
+		TargetLang string
+		Units      []*Unit
+	}
+
+	Unit struct {
+		Key    string
+		Source string
+		Target string
+		State  string
+	}
+
+	xliff12 struct {
+		XMLName xml.Name      `xml:"urn:oasis:names:tc:xliff:document:1.2 xliff"`
+		Version string        `xml:"version,attr"`
+		Files   []xliff12File `xml:"file"`
+	}
+	xliff12File struct {
+		Original       string        `xml:"original,attr"`
+		SourceLanguage string        `xml:"source-language,attr"`
+		TargetLanguage string        `xml:"target-language,attr"`
+		Datatype       string        `xml:"datatype,attr"`
+		Units          []xliff12Unit `xml:"body>trans-unit"`
+	}
+	xliff12Unit struct {
+		ID     string        `xml:"id,attr"`
+		Source string        `xml:"source"`
+		Target xliff12Target `xml:"target"`
+	}
+	xliff12Target struct {
+		State string `xml:"state,attr,omitempty"`
+		Text  string `xml:",chardata"`
+	}
+
+	xliff20 struct {
+		XMLName xml.Name      `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+		Version string        `xml:"version,attr"`
+		SrcLang string        `xml:"srcLang,attr"`
+		TrgLang string        `xml:"trgLang,attr"`
+		Files   []xliff20File `xml:"file"`
+	}
+	xliff20File struct {
+		ID       string        `xml:"id,attr"`
+		Original string        `xml:"original,attr,omitempty"`
+		Units    []xliff20Unit `xml:"unit"`
+	}
+	xliff20Unit struct {
+		ID       string           `xml:"id,attr"`
+		Name     string           `xml:"name,attr,omitempty"`
+		Segments []xliff20Segment `xml:"segment"`
+	}
+	xliff20Segment struct {
+		State    string `xml:"state,attr,omitempty"`
+		SubState string `xml:"subState,attr,omitempty"`
+		Source   string `xml:"source"`
+		Target   string `xml:"target"`
+	}
+)
+
+// NewDocument collects the source items of the target with their current
+// translations. The lock tells which translations are written by the AI.
+func NewDocument(source, target *parser.LocaleFileContent, lock *lockfile.Lock) *Document {
+	doc := &Document{
+		Original:   target.Path,
+		SourceLang: source.Code,
+		TargetLang: target.Code,
+	}
+
+	sourceItems := source.SourceItemsFor(target)
+	keys := make([]string, 0, len(sourceItems))
+	for k := range sourceItems {
+		if sourceItems.GetString(k) != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		u := &Unit{
+			Key:    k,
+			Source: sourceItems.GetString(k),
+			Target: target.LocaleItemsMap.GetString(k),
+			State:  StateTranslated,
+		}
+		if u.Target == "" {
+			u.State = StateNew
+		} else if e := lock.Get(target, k); e != nil && e.Machine {
+			u.State = StateNeedsReview
+		}
+		doc.Units = append(doc.Units, u)
+	}
+	return doc
+}
+
+// Marshal renders the document as XLIFF of the version.
+func (d *Document) Marshal(version string) ([]byte, error) {
+	var v interface{}
+	switch version {
+	case Version12:
+		file := xliff12File{
+			Original:       d.Original,
+			SourceLanguage: d.SourceLang,
+			TargetLanguage: d.TargetLang,
+			Datatype:       "plaintext",
+		}
+		for _, u := range d.Units {
+			file.Units = append(file.Units, xliff12Unit{
+				ID:     encodeKey(u.Key),
+				Source: u.Source,
+				Target: xliff12Target{State: u.State, Text: u.Target},
+			})
+		}
+		v = &xliff12{Version: Version12, Files: []xliff12File{file}}
+	case Version20:
+		file := xliff20File{ID: "f1", Original: d.Original}
+		for ix, u := range d.Units {
+			seg := xliff20Segment{Source: u.Source, Target: u.Target}
+			seg.State, seg.SubState = state20(u.State)
+			file.Units = append(file.Units, xliff20Unit{
+				// the id must be a NMTOKEN, so the key goes to the name
+				ID:       "u" + strconv.Itoa(ix+1),
+				Name:     encodeKey(u.Key),
+				Segments: []xliff20Segment{seg},
+			})
+		}
+		v = &xliff20{Version: Version20, SrcLang: d.SourceLang, TrgLang: d.TargetLang, Files: []xliff20File{file}}
+	default:
+		return nil, fmt.Errorf("unsupported XLIFF version %s. use %s or %s", version, Version12, Version20)
+	}
+
+	buf, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(buf, '\n')...), nil
+}
+
+// Unmarshal reads the documents of a XLIFF 1.2 or 2.0 file.
+func Unmarshal(buf []byte) ([]*Document, error) {
+	var probe struct {
+		Version string `xml:"version,attr"`
+	}
+	if err := xml.Unmarshal(buf, &probe); err != nil {
+		return nil, err
+	}
+
+	docs := make([]*Document, 0)
+	switch probe.Version {
+	case Version12:
+		var x xliff12
+		if err := xml.NewDecoder(bytes.NewReader(buf)).Decode(&x); err != nil {
+			return nil, err
+		}
+		for _, file := range x.Files {
+			doc := &Document{
+				Original:   file.Original,
+				SourceLang: file.SourceLanguage,
+				TargetLang: file.TargetLanguage,
+			}
+			for _, u := range file.Units {
+				doc.Units = append(doc.Units, &Unit{
+					Key:    decodeKey(u.ID),
+					Source: u.Source,
+					Target: u.Target.Text,
+					State:  u.Target.State,
+				})
+			}
+			docs = append(docs, doc)
+		}
+	case Version20:
+		var x xliff20
+		if err := xml.NewDecoder(bytes.NewReader(buf)).Decode(&x); err != nil {
+			return nil, err
+		}
+		for _, file := range x.Files {
+			doc := &Document{
+				Original:   file.Original,
+				SourceLang: x.SrcLang,
+				TargetLang: x.TrgLang,
+			}
+			for _, u := range file.Units {
+				unit := &Unit{Key: decodeKey(u.Name)}
+				if u.Name == "" {
+					unit.Key = u.ID
+				}
+				for _, seg := range u.Segments {
+					unit.Source += seg.Source
+					unit.Target += seg.Target
+					unit.State = state12(seg.State, seg.SubState)
+				}
+				doc.Units = append(doc.Units, unit)
+			}
+			docs = append(docs, doc)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported XLIFF version %q", probe.Version)
+	}
+	return docs, nil
+}
+
+// encodeKey writes the characters XML does not allow, e.g. the "\x04" between
+// the context and the message of a PO key, as \uXXXX. A backslash which would
+// be read as an escape is doubled, so decodeKey always gives the key back.
+func encodeKey(key string) string {
+	var sb strings.Builder
+	for i, r := range key {
+		next, _ := utf8.DecodeRuneInString(key[i+1:])
+		switch {
+		case r == '\\' && i+1 < len(key) && (next == '\\' || next == 'u' || !isXMLChar(next)):
+			sb.WriteString(`\\`)
+		case !isXMLChar(r):
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// decodeKey reverses encodeKey
+func decodeKey(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			if s[i+1] == '\\' {
+				sb.WriteByte('\\')
+				i++
+				continue
+			}
+			if s[i+1] == 'u' && i+6 <= len(s) {
+				if r, err := strconv.ParseUint(s[i+2:i+6], 16, 32); err == nil {
+					sb.WriteRune(rune(r))
+					i += 5
+					continue
+				}
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// isXMLChar reports whether XML 1.0 allows the character
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
+
+// state20 converts a XLIFF 1.2 state to the state and subState of XLIFF 2.0
+func state20(state string) (string, string) {
+	switch state {
+	case StateNew:
+		return "initial", ""
+	case StateNeedsReview:
+		return "translated", subState20NeedsReview
+	case StateSignedOff:
+		return "reviewed", ""
+	case StateFinal:
+		return "final", ""
+	}
+	return "translated", ""
+}
+
+// state12 converts the state and subState of XLIFF 2.0 to a XLIFF 1.2 state
+func state12(state, subState string) string {
+	switch state {
+	case "initial":
+		return StateNew
+	case "reviewed":
+		return StateSignedOff
+	case "final":
+		return StateFinal
+	}
+	if subState == subState20NeedsReview {
+		return StateNeedsReview
+	}
+	return StateTranslated
+}
@@ -0,0 +1,104 @@
+package xliff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quailyquaily/translate-cli/cmd/lockfile"
+	"github.com/quailyquaily/translate-cli/cmd/parser"
+)
+
+func TestEncodeKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"home/title", "home/title"},
+		{`a\/b`, `a\/b`},
+		{"menu\x04Open", `menu\u0004Open`},
+		{`path\u0004`, `path\\u0004`},
+		{`a\\b`, `a\\\b`},
+		{"a\\\x04", `a\\\u0004`},
+		{`end\`, `end\`},
+	}
+	for _, tt := range tests {
+		got := encodeKey(tt.key)
+		if got != tt.want {
+			t.Errorf("encodeKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+		if back := decodeKey(got); back != tt.key {
+			t.Errorf("decodeKey(%q) = %q, want %q", got, back, tt.key)
+		}
+	}
+}
+
+func TestExportImportContextKey(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	sourcePath := write("en.po", `msgid ""
+msgstr ""
+"Language: en\n"
+
+msgctxt "menu"
+msgid "Open"
+msgstr ""
+
+msgid "Close"
+msgstr ""
+`)
+	write("ja.po", `msgid ""
+msgstr ""
+"Language: ja\n"
+
+msgctxt "menu"
+msgid "Open"
+msgstr "開く"
+
+msgid "Close"
+msgstr "閉じる"
+`)
+
+	source, err := parser.NewSourceFromFile(sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := source.FindTargets(dir, false)
+	if len(targets) != 1 {
+		t.Fatalf("found %d targets, want 1", len(targets))
+	}
+	lock, err := lockfile.Load(filepath.Join(dir, lockfile.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := NewDocument(source, targets[0], lock)
+
+	for _, version := range []string{Version12, Version20} {
+		buf, err := doc.Marshal(version)
+		if err != nil {
+			t.Fatalf("marshal %s: %s", version, err)
+		}
+		docs, err := Unmarshal(buf)
+		if err != nil {
+			t.Fatalf("unmarshal %s: %s", version, err)
+		}
+		if len(docs) != 1 || len(docs[0].Units) != len(doc.Units) {
+			t.Fatalf("%s: got %d documents, want 1 with %d units", version, len(docs), len(doc.Units))
+		}
+		for ix, u := range docs[0].Units {
+			want := doc.Units[ix]
+			if u.Key != want.Key || u.Target != want.Target {
+				t.Errorf("%s: unit %d is %q: %q, want %q: %q", version, ix, u.Key, u.Target, want.Key, want.Target)
+			}
+			if _, ok := source.LocaleItemsMap[u.Key]; !ok {
+				t.Errorf("%s: key %q is not in the source", version, u.Key)
+			}
+		}
+	}
+}
@@ -1,25 +1,63 @@
 package assistant
 
 import (
+	"fmt"
+	"log/slog"
 	"sync"
 
 	"github.com/lyricat/goutils/ai"
+	"github.com/quailyquaily/translate-cli/internal/placeholder"
 )
 
 type (
 	Assistant struct {
-		cfg    Config
-		aiInst *ai.Instant
+		cfg     Config
+		aiInst  *ai.Instant
+		limiter *limiter
+		// onWarning receives the problems the assistant works around,
+		// e.g. retries. They are logged if it is nil.
+		onWarning func(msg string)
 		sync.Mutex
 	}
 	Config struct {
 		Provider string
+		// MaxRetries is the number of retries after transient errors, e.g. 429
+		MaxRetries int
+		// RPM and TPM limit the requests and the tokens per minute of all
+		// concurrent requests, 0 means no limit
+		RPM int
+		TPM int
+		// Mask replaces the placeholders with tokens before they are sent
+		// to the model, nil sends them as they are
+		Mask *placeholder.Masker
 	}
 )
 
 func New(cfg Config, aiInst *ai.Instant) *Assistant {
 	return &Assistant{
-		cfg:    cfg,
-		aiInst: aiInst,
+		cfg:     cfg,
+		aiInst:  aiInst,
+		limiter: &limiter{rpm: cfg.RPM, tpm: cfg.TPM},
 	}
 }
+
+// OnWarning sets the function which receives the warnings, e.g. about retries
+// and dropped keys.
+func (a *Assistant) OnWarning(fn func(msg string)) {
+	a.Lock()
+	defer a.Unlock()
+	a.onWarning = fn
+}
+
+func (a *Assistant) warn(format string, args ...any) {
+	a.Lock()
+	fn := a.onWarning
+	a.Unlock()
+
+	msg := fmt.Sprintf(format, args...)
+	if fn == nil {
+		slog.Warn("[assistant] " + msg)
+		return
+	}
+	fn(msg)
+}
@@ -0,0 +1,170 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultMaxRetries is the number of retries after transient errors
+	DefaultMaxRetries = 5
+
+	retryBaseDelay = time.Second
+	retryMaxDelay  = time.Minute
+	requestTimeout = 3 * time.Minute
+)
+
+type (
+	// limiter keeps the requests of the last minute, so the requests per
+	// minute and the tokens per minute of the provider are not exceeded.
+	limiter struct {
+		rpm     int
+		tpm     int
+		history []limiterRecord
+	}
+
+	limiterRecord struct {
+		at     time.Time
+		tokens int
+	}
+)
+
+// EstimateTokens guesses the number of tokens of a text: about 4 ASCII
+// characters per token and one token per other character, e.g. CJK.
+func EstimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// wait blocks until a request with the tokens fits into the limits
+func (a *Assistant) wait(ctx context.Context, tokens int) error {
+	for {
+		a.Lock()
+		delay := a.limiter.reserve(time.Now(), tokens)
+		a.Unlock()
+		if delay == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reserve records the request and returns 0 if it fits into the limits,
+// or how long to wait before trying again
+func (l *limiter) reserve(now time.Time, tokens int) time.Duration {
+	if l.rpm <= 0 && l.tpm <= 0 {
+		return 0
+	}
+
+	start := now.Add(-time.Minute)
+	for len(l.history) > 0 && !l.history[0].at.After(start) {
+		l.history = l.history[1:]
+	}
+
+	used := 0
+	for _, r := range l.history {
+		used += r.tokens
+	}
+
+	fits := l.rpm <= 0 || len(l.history) < l.rpm
+	// a request larger than the limit is sent alone
+	if l.tpm > 0 && used+tokens > l.tpm && len(l.history) > 0 {
+		fits = false
+	}
+	if fits {
+		l.history = append(l.history, limiterRecord{at: now, tokens: tokens})
+		return 0
+	}
+
+	// wait for the oldest request to leave the window
+	return l.history[0].at.Sub(start) + 10*time.Millisecond
+}
+
+// request runs fn with a timeout for each attempt, and retries it after
+// transient errors, e.g. 429 and 5xx responses, with jittered exponential backoff.
+func (a *Assistant) request(ctx context.Context, tokens int, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		if err := a.wait(ctx, tokens); err != nil {
+			return err
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		err := fn(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= a.cfg.MaxRetries || ctx.Err() != nil || !isTransient(err) {
+			return err
+		}
+
+		delay := backoff(attempt)
+		a.warn("request failed: %s. retry %d/%d in %s", err, attempt+1, a.cfg.MaxRetries, delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt, between half and the
+// full exponential delay
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << attempt
+	if d > retryMaxDelay || d <= 0 {
+		d = retryMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return isTransientStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return isTransientStatus(reqErr.HTTPStatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// other providers only give the message
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"status code: 429", "too many requests", "rate limit", "timeout", "connection reset"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTransientStatus(code int) bool {
+	return code == 408 || code == 409 || code == 429 || code >= 500
+}
@@ -0,0 +1,149 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, retryBaseDelay / 2, retryBaseDelay},
+		{1, retryBaseDelay, 2 * retryBaseDelay},
+		{3, 4 * retryBaseDelay, 8 * retryBaseDelay},
+		{10, retryMaxDelay / 2, retryMaxDelay},
+		{80, retryMaxDelay / 2, retryMaxDelay},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if d := backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestLimiterReserve(t *testing.T) {
+	now := time.Now()
+	type reservation struct {
+		after  time.Duration
+		tokens int
+		wait   bool
+	}
+	tests := []struct {
+		name     string
+		rpm, tpm int
+		requests []reservation
+	}{
+		{"no limits", 0, 0, []reservation{{0, 1000, false}, {0, 1000, false}, {0, 1000, false}}},
+		{"rpm", 2, 0, []reservation{{0, 10, false}, {time.Second, 10, false}, {2 * time.Second, 10, true}}},
+		{"rpm window passed", 2, 0, []reservation{{0, 10, false}, {time.Second, 10, false}, {time.Minute, 10, false}}},
+		{"tpm", 0, 100, []reservation{{0, 60, false}, {time.Second, 60, true}, {time.Second, 40, false}}},
+		{"tpm window passed", 0, 100, []reservation{{0, 60, false}, {time.Minute + time.Second, 60, false}}},
+		{"larger than tpm alone", 0, 100, []reservation{{0, 500, false}, {time.Second, 1, true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &limiter{rpm: tt.rpm, tpm: tt.tpm}
+			for ix, r := range tt.requests {
+				delay := l.reserve(now.Add(r.after), r.tokens)
+				if (delay > 0) != r.wait {
+					t.Errorf("request %d: got delay %s, want wait: %v", ix, delay, r.wait)
+				}
+			}
+		})
+	}
+
+	// the delay ends when the oldest request leaves the window
+	l := &limiter{rpm: 1}
+	l.reserve(now, 1)
+	if delay := l.reserve(now.Add(20*time.Second), 1); delay < 40*time.Second || delay > 41*time.Second {
+		t.Errorf("got delay %s, want about 40s", delay)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("request: %w", context.DeadlineExceeded), true},
+		{&openai.APIError{HTTPStatusCode: 429}, true},
+		{&openai.APIError{HTTPStatusCode: 503}, true},
+		{&openai.APIError{HTTPStatusCode: 401}, false},
+		{&openai.RequestError{HTTPStatusCode: 502}, true},
+		{&openai.RequestError{HTTPStatusCode: 400}, false},
+		{errors.New("error, status code: 429, message: Rate limit reached"), true},
+		{errors.New("read tcp: connection reset by peer"), true},
+		{errors.New("invalid api key"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	transient := &openai.APIError{HTTPStatusCode: 429}
+	tests := []struct {
+		name       string
+		maxRetries int
+		errs       []error
+		calls      int
+		ok         bool
+	}{
+		{"success", 3, nil, 1, true},
+		{"transient then success", 3, []error{transient}, 2, true},
+		{"no retries", 0, []error{transient}, 1, false},
+		{"not transient", 3, []error{errors.New("invalid api key")}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(Config{MaxRetries: tt.maxRetries}, nil)
+			warnings := 0
+			a.OnWarning(func(string) { warnings++ })
+
+			calls := 0
+			err := a.request(context.Background(), 1, func(ctx context.Context) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok: %v", err, tt.ok)
+			}
+			if calls != tt.calls {
+				t.Errorf("got %d calls, want %d", calls, tt.calls)
+			}
+			if warnings != calls-1 {
+				t.Errorf("got %d warnings for %d retries", warnings, calls-1)
+			}
+		})
+	}
+
+	// a canceled request is not retried
+	ctx, cancel := context.WithCancel(context.Background())
+	a := New(Config{MaxRetries: 3}, nil)
+	a.OnWarning(func(string) {})
+	calls := 0
+	err := a.request(ctx, 1, func(context.Context) error {
+		calls++
+		cancel()
+		return transient
+	})
+	if err != transient || calls != 1 {
+		t.Errorf("got error %v after %d calls, want %v after 1 call", err, calls, transient)
+	}
+}
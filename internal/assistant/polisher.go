@@ -3,7 +3,6 @@ package assistant
 import (
 	"context"
 	"fmt"
-	"time"
 )
 
 type PolishInput struct {
@@ -13,9 +12,6 @@ type PolishInput struct {
 }
 
 func (a *Assistant) Polish(ctx context.Context, input *PolishInput) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
-	defer cancel()
-
 	jaPrompt := fmt.Sprintf(`
 	あなたはプロの日本語ライティングアシスタントです。
 	ユーザーが日常や仕事で作成する日本語のメッセージを、以下の基準に基づいて校正し、より効果的で自然な表現に仕上げます。
@@ -39,5 +35,11 @@ func (a *Assistant) Polish(ctx context.Context, input *PolishInput) (string, err
 		return input.Content, nil
 	}
 
-	return a.AIRequestText(ctx, inst)
+	var result string
+	err := a.request(ctx, EstimateTokens(inst)+EstimateTokens(input.Content), func(ctx context.Context) error {
+		var err error
+		result, err = a.AIRequestText(ctx, inst)
+		return err
+	})
+	return result, err
 }
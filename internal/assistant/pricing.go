@@ -0,0 +1,41 @@
+package assistant
+
+import "strings"
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// knownPrices are the list prices of common models. They change over time,
+// the config can override them.
+var knownPrices = map[string]Price{
+	"gpt-4o":        {Input: 2.5, Output: 10},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.6},
+	"gpt-4.1":       {Input: 2, Output: 8},
+	"gpt-4.1-mini":  {Input: 0.4, Output: 1.6},
+	"gpt-4.1-nano":  {Input: 0.1, Output: 0.4},
+	"deepseek-chat": {Input: 0.27, Output: 1.1},
+}
+
+// PriceOf returns the known price of the model. Dated versions use the price
+// of their model, e.g. "gpt-4o-mini-2024-07-18".
+func PriceOf(model string) (Price, bool) {
+	model = strings.ToLower(model)
+	best := ""
+	for name := range knownPrices {
+		if (model == name || strings.HasPrefix(model, name+"-")) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return knownPrices[best], true
+}
+
+// Cost returns the cost in USD of the tokens
+func (p Price) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
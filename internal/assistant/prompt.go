@@ -3,6 +3,8 @@ package assistant
 import (
 	"bytes"
 	"text/template"
+
+	"github.com/quailyquaily/translate-cli/cmd/parser"
 )
 
 const backgroundTplM = `Here is the background (just for reference, you must not use it for rewriting):
@@ -27,9 +29,13 @@ const outputJSONTranslateJapaneseTplM = `* 必ずプレーンJSON形式で直接
 * 出力例: { "key1": "value1", "key2": "value2" }
 * "key1" は元のキーで、"value" は書き換えられた値です。`
 
+const pluralEnglishTplM = `* the keys which differ only in the plural category, e.g. "files_one" and "files_other", or "files[one]" and "files[other]", are the plural forms of one text. write each form for its CLDR plural category in {{ .Lang }}, and keep the forms consistent.`
+
+const pluralJapaneseTplM = `* 複数カテゴリだけが異なるキー（例: "files_one" と "files_other"、"files[one]" と "files[other]"）は同じテキストの複数形です。それぞれを {{ .Lang }} の CLDR 複数カテゴリに合わせて書き、形をそろえてください。`
+
 const translateEnglishTpl = `
 You are an expert linguist, specializing in {{ .Input.Lang }} language.
-Rewrite following text in {{ .Input.Lang }} language and by ensuring:
+Rewrite following {{ with .Input.SourceLang }}{{ . }} {{ end }}text in {{ .Input.Lang }} language and by ensuring:
 
 * accuracy (by correcting errors of addition, mistranslation, omission, or untranslated text),
 * fluency (by applying {{ .Input.Lang }} grammar, spelling and punctuation rules and ensuring there are no unnecessary repetitions),
@@ -37,6 +43,9 @@ Rewrite following text in {{ .Input.Lang }} language and by ensuring:
 * terminology (by ensuring terminology use is consistent and reflects the source text domain; and by only ensuring you use equivalent idioms of {{ .Input.Lang }}),
 * 禁止使用 “您”，“您好”，“您的” 等词汇。
 * if there is emoji in the original text, you must keep it.
+{{- if .Input.Masked }}
+* tokens like ⟦0⟧ are placeholders, you must keep them as they are.
+{{- end }}
 {{ .OutputPart }}
 
 {{ .BackgroundPart }}
@@ -49,19 +58,22 @@ Here is the text need to be rewritten:
 `
 
 const translateJapaneseTplM = `
-あなたはプロの日本語と{{ .Input.Lang }} のライティングアシスタントです。
-以下の文章を{{ .Input.Lang }}から日本語に書き換えてください。
+あなたはプロの日本語{{ with .Input.SourceLang }}と{{ . }} {{ end }}のライティングアシスタントです。
+以下の文章を{{ with .Input.SourceLang }}{{ . }}から{{ end }}日本語に書き換えてください。
 
 * 正確性（追加、誤訳、漏訳、未訳のエラーを修正）
-* 流暢さ（{{ .Input.Lang }} の文法、スペル、句読点のルールを適用し、不要な繰り返しを避ける）
+* 流暢さ（日本語の文法、スペル、句読点のルールを適用し、不要な繰り返しを避ける）
 * スタイル（常にオリジナルのソーステキストのスタイルに従う）
-* 用語（用語の使用が一貫していて、ソーステキストのドメインを反映していることを確認し、{{ .Input.Lang }} の同等の慣用句のみを使用する）
+* 用語（用語の使用が一貫していて、ソーステキストのドメインを反映していることを確認し、日本語の同等の慣用句のみを使用する）
 * ユーザーが日常や仕事で作成する日本語のメッセージを、以下の基準に基づいて校正し、より効果的で自然な表現に仕上げます。
 * メッセージを短くわかりやすく、丁寧かつフレンドリーに、そして失礼のないトーンで整えてください。
 * 嫌味や誤解を避け、柔らかい表現に修正することを心がけます。
 * ユーザーの意図を正確に汲み取り、より伝わりやすい文章に仕上げることを目標とします。
 * この文章を簡潔で伝わりやすくしてください。
 * オリジナルのテキストに絵文字が含まれている場合、それを維持してください。
+{{- if .Input.Masked }}
+* ⟦0⟧ のようなトークンはプレースホルダーです。そのまま残してください。
+{{- end }}
 {{ .OutputPart }}
 
 {{ .BackgroundPart }}
@@ -88,6 +100,8 @@ func init() {
 		"outputPlaintextTranslateJapanese", outputPlaintextJapaneseTplM,
 		"outputJSONTranslateEnglish", outputJSONTranslateEnglishTplM,
 		"outputJSONTranslateJapanese", outputJSONTranslateJapaneseTplM,
+		"pluralEnglish", pluralEnglishTplM,
+		"pluralJapanese", pluralJapaneseTplM,
 	}
 	for ix := 0; ix < len(tplms); ix += 2 {
 		tpls[tplms[ix]] = template.Must(template.New(tplms[ix]).Parse(tplms[ix+1]))
@@ -132,6 +146,9 @@ func (input *TranslateInput) GetTranslatePrompt() string {
 			outputPart = MustExecuteTemplate(tpls["outputPlaintextTranslateJapanese"], input)
 		} else {
 			outputPart = MustExecuteTemplate(tpls["outputJSONTranslateJapanese"], input)
+			if input.hasPlurals() {
+				outputPart += "\n" + MustExecuteTemplate(tpls["pluralJapanese"], input)
+			}
 		}
 		inst = MustExecuteTemplate(tpls["translateJapanese"], map[string]interface{}{
 			"BackgroundPart": bgPart,
@@ -145,6 +162,9 @@ func (input *TranslateInput) GetTranslatePrompt() string {
 			outputPart = MustExecuteTemplate(tpls["outputPlaintextTranslateEnglish"], input)
 		} else {
 			outputPart = MustExecuteTemplate(tpls["outputJSONTranslateEnglish"], input)
+			if input.hasPlurals() {
+				outputPart += "\n" + MustExecuteTemplate(tpls["pluralEnglish"], input)
+			}
 		}
 		inst = MustExecuteTemplate(tpls["translateEnglish"], map[string]interface{}{
 			"BackgroundPart": bgPart,
@@ -157,3 +177,17 @@ func (input *TranslateInput) GetTranslatePrompt() string {
 
 	return inst
 }
+
+// hasPlurals reports whether the items have more than one plural form of a text
+func (input *TranslateInput) hasPlurals() bool {
+	seen := make(map[string]bool)
+	for k := range input.ContentItems {
+		if base, ok := parser.PluralBase(k); ok {
+			if seen[base] {
+				return true
+			}
+			seen[base] = true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package assistant
+
+import (
+	"sort"
+
+	"github.com/lyricat/goutils/ai"
+)
+
+// Provider tells how a provider is configured and called
+type Provider struct {
+	// Section is the config section of the settings of the provider
+	Section string
+	// Settings are the settings in Section which must be set
+	Settings []string
+	// OpenAICompatible providers are called with the OpenAI API, the others
+	// with the API of Susanoo
+	OpenAICompatible bool
+}
+
+// Providers are the supported providers. The OpenAI compatible providers
+// share the openai section.
+var Providers = map[string]Provider{
+	ai.ProviderOpenAI:   {Section: "openai", Settings: []string{"api_key", "model"}, OpenAICompatible: true},
+	ai.ProviderDeepseek: {Section: "openai", Settings: []string{"api_key", "model"}, OpenAICompatible: true},
+	ai.ProviderXAI:      {Section: "openai", Settings: []string{"api_key", "model"}, OpenAICompatible: true},
+	ai.ProviderSusanoo:  {Section: "susanoo", Settings: []string{"api_key", "api_base"}},
+}
+
+// ProviderNames returns the names of the supported providers in sorted order
+func ProviderNames() []string {
+	names := make([]string, 0, len(Providers))
+	for name := range Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -3,12 +3,24 @@ package assistant
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/lyricat/goutils/ai"
 )
 
+// ErrInvalidOutput is returned if the model does not answer with JSON
+var ErrInvalidOutput = errors.New("the output is not valid JSON")
+
+// ErrRejected is returned if the translation keeps failing the placeholder
+// checks, e.g. a placeholder is translated or dropped
+var ErrRejected = errors.New("the translation is rejected")
+
 func (a *Assistant) AIRequestJSON(ctx context.Context, inst string) (*ai.Result, error) {
-	if a.cfg.Provider == ai.ProviderSusanoo {
+	provider, ok := Providers[a.cfg.Provider]
+	if !ok {
+		return nil, errors.New("unsupported provider")
+	}
+	if !provider.OpenAICompatible {
 		rp := &ai.SusanoParams{
 			Format: "json",
 			Conditions: ai.SusanoParamsConditions{
@@ -21,25 +33,27 @@ func (a *Assistant) AIRequestJSON(ctx context.Context, inst string) (*ai.Result,
 			return nil, err
 		}
 		return ret, nil
-	} else if a.cfg.Provider == ai.ProviderOpenAI {
-		ret, err := a.aiInst.OneTimeRequestWithParams(ctx, inst, map[string]any{})
-		if err != nil {
-			return nil, err
-		}
-		// extract json from the response
-		json, err := a.aiInst.GrabJsonOutput(ctx, ret.Text)
-		if err != nil {
-			return nil, err
-		}
-		ret.Json = json
-		return ret, nil
 	}
 
-	return nil, errors.New("unsupported provider")
+	ret, err := a.aiInst.OneTimeRequestWithParams(ctx, inst, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	// extract json from the response
+	json, err := a.aiInst.GrabJsonOutput(ctx, ret.Text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOutput, err)
+	}
+	ret.Json = json
+	return ret, nil
 }
 
 func (a *Assistant) AIRequestText(ctx context.Context, inst string) (string, error) {
-	if a.cfg.Provider == ai.ProviderSusanoo {
+	provider, ok := Providers[a.cfg.Provider]
+	if !ok {
+		return "", errors.New("unsupported provider")
+	}
+	if !provider.OpenAICompatible {
 		rp := &ai.SusanoParams{
 			Format: "plaintext",
 			Conditions: ai.SusanoParamsConditions{
@@ -52,13 +66,11 @@ func (a *Assistant) AIRequestText(ctx context.Context, inst string) (string, err
 			return "", err
 		}
 		return ret.Text, nil
-	} else if a.cfg.Provider == ai.ProviderOpenAI {
-		ret, err := a.aiInst.OneTimeRequestWithParams(ctx, inst, map[string]any{})
-		if err != nil {
-			return "", err
-		}
-		return ret.Text, nil
 	}
 
-	return "", errors.New("unsupported provider")
+	ret, err := a.aiInst.OneTimeRequestWithParams(ctx, inst, map[string]any{})
+	if err != nil {
+		return "", err
+	}
+	return ret.Text, nil
 }
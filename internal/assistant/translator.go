@@ -2,13 +2,20 @@ package assistant
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
+	"sort"
+	"strings"
 
+	"github.com/lyricat/goutils/ai"
 	"github.com/lyricat/goutils/structs"
 	"github.com/quailyquaily/translate-cli/cmd/parser"
+	"github.com/quailyquaily/translate-cli/internal/placeholder"
 )
 
+// maxInvalidRetries is the number of retries of a translation which breaks its placeholders
+const maxInvalidRetries = 2
+
 type (
 	TranslateInput struct {
 		// for single translate
@@ -19,42 +26,294 @@ type (
 
 		Lang       string                  // language name, e.g. "English", "Japanese"
 		LangCode   string                  // language code, e.g. "en", "ja"
+		SourceLang string                  // language name of the source, e.g. "English"
 		Background string                  // the background of the translation
 		Glossary   *parser.GlossaryMapItem // the glossary of the translation
+		Masked     bool                    // the placeholders are replaced by tokens, e.g. "⟦0⟧"
+		Forms      []string                // the source texts of the other plural forms of Key
 	}
 )
 
+// EstimateTokens guesses the tokens of the request and of the response
+func (input *TranslateInput) EstimateTokens() int {
+	prompt, output := input.EstimateUsage()
+	return prompt + output
+}
+
+// EstimateUsage guesses the tokens of the prompt, and of the response, which
+// is about as long as the content
+func (input *TranslateInput) EstimateUsage() (prompt, output int) {
+	content := input.Content
+	if input.Content == "" {
+		content = input.ContentItems.Dump()
+	}
+	return EstimateTokens(input.GetTranslatePrompt()), EstimateTokens(content)
+}
+
+// Translate translates the content. A translation which breaks the
+// placeholders of the content is asked again, and ErrRejected is returned if
+// it is still broken after maxInvalidRetries.
 func (a *Assistant) Translate(ctx context.Context, input *TranslateInput) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
-	defer cancel()
+	masked, parts := a.maskSingle(input)
+	inst := masked.GetTranslatePrompt()
 
-	inst := input.GetTranslatePrompt()
+	for attempt := 0; ; attempt++ {
+		var result string
+		err := a.request(ctx, masked.EstimateTokens(), func(ctx context.Context) error {
+			var err error
+			result, err = a.AIRequestText(ctx, inst)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
 
-	return a.AIRequestText(ctx, inst)
+		result, problem := a.checkTranslation(input.Content, input.Forms, result, parts)
+		if problem == nil {
+			return result, nil
+		}
+		if attempt >= maxInvalidRetries {
+			return "", fmt.Errorf("%w: %s", ErrRejected, problem)
+		}
+		a.warn("the translation of %q is invalid: %s. retry %d/%d", input.Key, problem, attempt+1, maxInvalidRetries)
+	}
 }
 
+// TranslateBatch translates the content items. The keys the model returns
+// are matched to the input even if they drift a bit, e.g. in white space or
+// nesting. Unknown keys are dropped. The missing keys are asked again in
+// smaller batches, down to one by one. On error, the items translated so far
+// are returned with it.
 func (a *Assistant) TranslateBatch(ctx context.Context, input *TranslateInput) (structs.JSONMap, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Minute)
-	defer cancel()
+	result := structs.NewJSONMap()
+	err := a.translateItems(ctx, input, input.ContentItems, result)
+	return result, err
+}
+
+func (a *Assistant) translateItems(ctx context.Context, input *TranslateInput, items, result structs.JSONMap) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if len(items) == 1 {
+		for k := range items {
+			text, err := a.Translate(ctx, singleInput(input, items, k))
+			if errors.Is(err, ErrRejected) {
+				// the other keys are still usable
+				a.warn("skip the key %q: %s", k, err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			result.SetValue(k, text)
+		}
+		return nil
+	}
+
+	batch, parts := a.maskBatch(input, items)
+	inst := batch.GetTranslatePrompt()
+
+	var ret *ai.Result
+	err := a.request(ctx, batch.EstimateTokens(), func(ctx context.Context) error {
+		var err error
+		ret, err = a.AIRequestJSON(ctx, inst)
+		return err
+	})
+	accepted := structs.NewJSONMap()
+	if err == nil {
+		var dropped []string
+		accepted, dropped = matchBatchOutput(items, ret.Json)
+		for _, k := range dropped {
+			a.warn("drop the key %q, it is not in the input", k)
+		}
+		// the invalid values are asked again like the missing ones
+		for k, v := range accepted {
+			text, problem := a.checkTranslation(items.GetString(k), pluralForms(input.ContentItems, k), v.(string), parts[k])
+			if problem != nil {
+				a.warn("the translation of %q is invalid: %s", k, problem)
+				delete(accepted, k)
+				continue
+			}
+			accepted[k] = text
+		}
+	} else if !errors.Is(err, ErrInvalidOutput) {
+		return err
+	}
+
+	missing := structs.NewJSONMap()
+	for k, v := range items {
+		if _, ok := accepted[k]; ok {
+			result.SetValue(k, accepted[k])
+		} else {
+			missing[k] = v
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if len(accepted) > 0 {
+		return a.translateItems(ctx, input, missing, result)
+	}
+	// nothing is usable, so the batch may be too large for the model
+	for _, half := range missing.Split((len(missing) + 1) / 2) {
+		if err := a.translateItems(ctx, input, half, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prompt returns the prompt which is sent first for the input. A batch of
+// one item is sent as a single text, like TranslateBatch does.
+func (a *Assistant) Prompt(input *TranslateInput) string {
+	if len(input.ContentItems) == 1 {
+		for k := range input.ContentItems {
+			masked, _ := a.maskSingle(singleInput(input, input.ContentItems, k))
+			return masked.GetTranslatePrompt()
+		}
+	}
+	if input.ContentItems != nil {
+		batch, _ := a.maskBatch(input, input.ContentItems)
+		return batch.GetTranslatePrompt()
+	}
+	masked, _ := a.maskSingle(input)
+	return masked.GetTranslatePrompt()
+}
+
+// singleInput returns the input of one key of the items of a batch
+func singleInput(input *TranslateInput, items structs.JSONMap, key string) *TranslateInput {
+	single := *input
+	single.Key, single.Content, single.ContentItems = key, items.GetString(key), nil
+	single.Forms = pluralForms(input.ContentItems, key)
+	return &single
+}
+
+// maskSingle returns the input with its placeholders masked, and the parts
+// which restore them
+func (a *Assistant) maskSingle(input *TranslateInput) (*TranslateInput, []string) {
+	masked := *input
+	var parts []string
+	if a.cfg.Mask != nil {
+		masked.Content, parts = a.cfg.Mask.Mask(input.Content)
+		masked.Masked = len(parts) > 0
+	}
+	return &masked, parts
+}
 
-	inst := input.GetTranslatePrompt()
+// maskBatch returns the input of the items with their placeholders masked,
+// and the parts which restore them by key
+func (a *Assistant) maskBatch(input *TranslateInput, items structs.JSONMap) (*TranslateInput, map[string][]string) {
+	batch := *input
+	batch.ContentItems = items
+	parts := make(map[string][]string)
+	if a.cfg.Mask != nil {
+		batch.ContentItems = structs.NewJSONMap()
+		for k := range items {
+			var text string
+			text, parts[k] = a.cfg.Mask.Mask(items.GetString(k))
+			batch.ContentItems.SetValue(k, text)
+			batch.Masked = batch.Masked || len(parts[k]) > 0
+		}
+	}
+	return &batch, parts
+}
 
-	ret, err := a.AIRequestJSON(ctx, inst)
-	if err != nil {
-		return nil, err
+// checkTranslation restores the masked parts of the translation and checks
+// its placeholders against the source, or the other plural forms of it
+func (a *Assistant) checkTranslation(source string, forms []string, translation string, parts []string) (string, error) {
+	if a.cfg.Mask != nil {
+		var err error
+		if translation, err = placeholder.Unmask(translation, parts); err != nil {
+			return "", err
+		}
 	}
+	return translation, placeholder.CheckPlural(source, forms, translation)
+}
 
-	// validate the result.
-	// the size of ret.Json should be the same as the size of input.ContentItems
-	if len(ret.Json) != len(input.ContentItems) {
-		return nil, fmt.Errorf("the size of result is not the same as the size of input")
+// pluralForms returns the source texts of the other keys of the group of the
+// key in the items, e.g. the forms of "files_one" or of msgstr[0] of a PO
+// file, or nil if the key is in no group
+func pluralForms(items structs.JSONMap, key string) []string {
+	base, ok := parser.KeyGroup(key)
+	if !ok {
+		return nil
 	}
-	// and all the keys should be in the input.ContentItems
-	for k := range ret.Json {
-		if _, ok := input.ContentItems[k]; !ok {
-			return nil, fmt.Errorf("the key %s is not in the input", k)
+	forms := make([]string, 0)
+	for k := range items {
+		if b, ok := parser.KeyGroup(k); ok && b == base && k != key {
+			forms = append(forms, items.GetString(k))
 		}
 	}
+	sort.Strings(forms)
+	return forms
+}
+
+// matchBatchOutput returns the values of the output whose keys match the
+// input keys. Nested objects are flattened, and keys which differ only in
+// case, white space or the separator match if there is one such input key.
+// The keys which match nothing are returned as dropped.
+func matchBatchOutput(items structs.JSONMap, output map[string]any) (structs.JSONMap, []string) {
+	flat := make(map[string]any)
+	flattenOutput(output, "", flat)
+
+	normalized := make(map[string][]string)
+	for k := range items {
+		nk := normalizeKey(k)
+		normalized[nk] = append(normalized[nk], k)
+	}
+
+	accepted := structs.NewJSONMap()
+	dropped := make([]string, 0)
+	for k, v := range flat {
+		text, ok := outputText(v)
+		if !ok {
+			continue
+		}
+		key := k
+		if _, ok := items[key]; !ok {
+			candidates := normalized[normalizeKey(k)]
+			if len(candidates) != 1 {
+				dropped = append(dropped, k)
+				continue
+			}
+			key = candidates[0]
+		}
+		if _, dup := accepted[key]; dup && key != k {
+			// the exact key wins over a drifted one
+			continue
+		}
+		accepted.SetValue(key, text)
+	}
+	sort.Strings(dropped)
+	return accepted, dropped
+}
+
+func flattenOutput(m map[string]any, prefix string, result map[string]any) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + parser.KeySeparator + k
+		}
+		if child, ok := v.(map[string]any); ok {
+			flattenOutput(child, key, result)
+		} else {
+			result[key] = v
+		}
+	}
+}
+
+func outputText(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, v != ""
+	case float64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
 
-	return ret.Json, nil
+func normalizeKey(k string) string {
+	k = strings.Join(strings.Fields(strings.ToLower(k)), "")
+	return strings.NewReplacer(".", "/", parser.KeySeparator, "/").Replace(k)
 }
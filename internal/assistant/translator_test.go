@@ -0,0 +1,32 @@
+package assistant
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lyricat/goutils/structs"
+)
+
+func TestPluralForms(t *testing.T) {
+	items := structs.JSONMap{
+		"files_one":   "One file",
+		"files_other": "{{count}} files",
+		"msg[0]":      "One message",
+		"msg[1]":      "%d messages",
+		"title":       "Title",
+	}
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"files_one", []string{"{{count}} files"}},
+		{"files_other", []string{"One file"}},
+		{"msg[0]", []string{"%d messages"}},
+		{"title", nil},
+	}
+	for _, tt := range tests {
+		if got := pluralForms(items, tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pluralForms(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package placeholder
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// icuArg is an argument of an ICU message, at any depth
+type icuArg struct {
+	name string
+	// kind is the argument type, e.g. "plural" or "number", or empty
+	kind string
+	// selectors are the branches of plural, selectordinal and select arguments
+	selectors []string
+}
+
+var (
+	icuNameRe     = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+	icuSelectorRe = regexp.MustCompile(`^(zero|one|two|few|many|other|=-?[0-9]+)$`)
+)
+
+type icuParser struct {
+	s    string
+	pos  int
+	args []icuArg
+}
+
+// parseICU returns the arguments of the message, or an error if the
+// message is malformed, e.g. a brace is not closed
+func parseICU(s string) ([]icuArg, error) {
+	p := &icuParser{s: s}
+	if err := p.message(0, false); err != nil {
+		return nil, err
+	}
+	return p.args, nil
+}
+
+// message reads text and arguments up to the end, or up to the "}" which
+// closes a branch if depth > 0
+func (p *icuParser) message(depth int, inPlural bool) error {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; c {
+		case '\'':
+			p.quoted(inPlural)
+		case '{':
+			p.pos++
+			if err := p.argument(depth, inPlural); err != nil {
+				return err
+			}
+		case '}':
+			if depth == 0 {
+				return fmt.Errorf("unexpected } at %d", p.pos)
+			}
+			return nil
+		default:
+			p.pos++
+		}
+	}
+	if depth > 0 {
+		return fmt.Errorf("missing }")
+	}
+	return nil
+}
+
+// quoted skips a quoted literal, e.g. '{' or '{not an argument}'. A doubled
+// apostrophe is a literal apostrophe.
+func (p *icuParser) quoted(inPlural bool) {
+	p.pos++
+	if p.pos >= len(p.s) {
+		return
+	}
+	next := p.s[p.pos]
+	if next == '\'' {
+		p.pos++
+		return
+	}
+	if next != '{' && next != '}' && next != '|' && !(inPlural && next == '#') {
+		return
+	}
+	for p.pos < len(p.s) {
+		if p.s[p.pos] == '\'' {
+			if p.pos+1 < len(p.s) && p.s[p.pos+1] == '\'' {
+				p.pos += 2
+				continue
+			}
+			p.pos++
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *icuParser) argument(depth int, inPlural bool) error {
+	p.skipSpace()
+	name := p.word()
+	if !icuNameRe.MatchString(name) {
+		return fmt.Errorf("invalid argument name %q", name)
+	}
+	arg := icuArg{name: name}
+	p.skipSpace()
+
+	if p.peek() == '}' {
+		p.pos++
+		p.args = append(p.args, arg)
+		return nil
+	}
+	if p.peek() != ',' {
+		return fmt.Errorf("expected , or } after {%s", name)
+	}
+	p.pos++
+	p.skipSpace()
+	arg.kind = p.word()
+	if arg.kind == "" {
+		return fmt.Errorf("missing the type of {%s}", name)
+	}
+	p.skipSpace()
+
+	switch arg.kind {
+	case "plural", "selectordinal", "select":
+		if p.peek() != ',' {
+			return fmt.Errorf("expected , after {%s, %s", name, arg.kind)
+		}
+		p.pos++
+		if err := p.branches(&arg, depth); err != nil {
+			return err
+		}
+	default:
+		// the style, e.g. "{n, number, percent}", up to the closing brace
+		if p.peek() == ',' {
+			p.pos++
+			for p.pos < len(p.s) && p.s[p.pos] != '}' {
+				if p.s[p.pos] == '\'' {
+					p.quoted(inPlural)
+					continue
+				}
+				if p.s[p.pos] == '{' {
+					return fmt.Errorf("unexpected { in the style of {%s}", name)
+				}
+				p.pos++
+			}
+		}
+		if p.peek() != '}' {
+			return fmt.Errorf("missing } of {%s}", name)
+		}
+		p.pos++
+	}
+	p.args = append(p.args, arg)
+	return nil
+}
+
+func (p *icuParser) branches(arg *icuArg, depth int) error {
+	plural := arg.kind != "select"
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return fmt.Errorf("missing } of {%s}", arg.name)
+		}
+		if p.s[p.pos] == '}' {
+			p.pos++
+			break
+		}
+
+		selector := p.word()
+		if plural && strings.HasPrefix(selector, "offset:") {
+			continue
+		}
+		if selector == "" {
+			return fmt.Errorf("missing a selector in {%s}", arg.name)
+		}
+		if plural && !icuSelectorRe.MatchString(selector) {
+			return fmt.Errorf("invalid plural selector %q in {%s}", selector, arg.name)
+		}
+		p.skipSpace()
+		if p.peek() != '{' {
+			return fmt.Errorf("expected { after %s in {%s}", selector, arg.name)
+		}
+		p.pos++
+		if err := p.message(depth+1, plural); err != nil {
+			return err
+		}
+		if p.peek() != '}' {
+			return fmt.Errorf("missing } of %s in {%s}", selector, arg.name)
+		}
+		p.pos++
+		arg.selectors = append(arg.selectors, selector)
+	}
+
+	for _, s := range arg.selectors {
+		if s == "other" {
+			return nil
+		}
+	}
+	return fmt.Errorf("missing the other branch of {%s}", arg.name)
+}
+
+// word reads up to white space or a syntax character
+func (p *icuParser) word() string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t\r\n{},", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *icuParser) skipSpace() {
+	for p.pos < len(p.s) && strings.ContainsRune(" \t\r\n", rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *icuParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// compareICU checks that the translation has the arguments of the source
+// with the same types. Plural branches follow the target language, the
+// branches of select must stay the same.
+func compareICU(source, translation []icuArg) error {
+	srcNames, dstNames := icuNames(source), icuNames(translation)
+	if err := sameSet("placeholder", srcNames, dstNames); err != nil {
+		return err
+	}
+
+	srcKinds, dstKinds := icuKinds(source), icuKinds(translation)
+	for _, name := range sortedKeys(toSet(srcNames)) {
+		src := strings.Join(sortedKeys(toSet(srcKinds[name])), ", ")
+		dst := strings.Join(sortedKeys(toSet(dstKinds[name])), ", ")
+		if src != dst {
+			return fmt.Errorf("the type of %s changed from %s to %s", name, src, dst)
+		}
+	}
+
+	srcSelect, dstSelect := icuSelectSelectors(source), icuSelectSelectors(translation)
+	for _, name := range sortedKeys(toSet(icuSelectNames(source))) {
+		if err := sameSet("the branch", srcSelect[name], dstSelect[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func icuSelectNames(args []icuArg) []string {
+	names := make([]string, 0)
+	for _, arg := range args {
+		if arg.kind == "select" {
+			names = append(names, arg.name)
+		}
+	}
+	return names
+}
+
+func icuNames(args []icuArg) []string {
+	names := make([]string, 0, len(args))
+	for _, arg := range args {
+		names = append(names, "{"+arg.name+"}")
+	}
+	return names
+}
+
+func icuKinds(args []icuArg) map[string][]string {
+	kinds := make(map[string][]string)
+	for _, arg := range args {
+		name := "{" + arg.name + "}"
+		kind := arg.kind
+		if kind == "" {
+			kind = "none"
+		}
+		kinds[name] = append(kinds[name], kind)
+	}
+	return kinds
+}
+
+func icuSelectSelectors(args []icuArg) map[string][]string {
+	selectors := make(map[string][]string)
+	for _, arg := range args {
+		if arg.kind == "select" {
+			for _, s := range arg.selectors {
+				selectors[arg.name] = append(selectors[arg.name], s+" of {"+arg.name+"}")
+			}
+		}
+	}
+	return selectors
+}
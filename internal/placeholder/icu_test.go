@@ -0,0 +1,77 @@
+package placeholder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseICU(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    []icuArg
+		ok      bool
+	}{
+		{"text", "Hello", nil, true},
+		{"simple argument", "Hi {name}", []icuArg{{name: "name"}}, true},
+		{"typed argument", "{n, number, percent} done", []icuArg{{name: "n", kind: "number"}}, true},
+		{"plural", "{n, plural, one {# file} other {# files}}", []icuArg{{name: "n", kind: "plural", selectors: []string{"one", "other"}}}, true},
+		{"plural offset and exact", "{n, plural, offset:1 =0 {none} other {# more}}", []icuArg{{name: "n", kind: "plural", selectors: []string{"=0", "other"}}}, true},
+		{"nested", "{g, select, male {{n, plural, other {He has #}}} other {They}}", []icuArg{
+			{name: "n", kind: "plural", selectors: []string{"other"}},
+			{name: "g", kind: "select", selectors: []string{"male", "other"}},
+		}, true},
+		{"quoted braces", "'{not an argument}' and it''s {x}", []icuArg{{name: "x"}}, true},
+		{"quoted hash in plural", "{n, plural, other {'#' #}}", []icuArg{{name: "n", kind: "plural", selectors: []string{"other"}}}, true},
+		{"unclosed argument", "Hi {name", nil, false},
+		{"unexpected close", "Hi }", nil, false},
+		{"invalid name", "{first name}", nil, false},
+		{"missing type", "{n, }", nil, false},
+		{"plural without other", "{n, plural, one {# file}}", nil, false},
+		{"invalid plural selector", "{n, plural, some {x} other {y}}", nil, false},
+		{"branch not closed", "{n, plural, other {# files}", nil, false},
+		{"brace in style", "{n, number, {x}}", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseICU(tt.message)
+			if (err == nil) != tt.ok {
+				t.Fatalf("parseICU(%q) = %v, want ok: %v", tt.message, err, tt.ok)
+			}
+			if tt.ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseICU(%q) = %+v, want %+v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareICU(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		translation string
+		ok          bool
+	}{
+		{"same", "Hi {name}", "Salut {name}", true},
+		{"plural branches of the language", "{n, plural, one {#} other {#}}", "{n, plural, other {#}}", true},
+		{"argument missing", "{a} and {b}", "{a}", false},
+		{"argument added", "{a}", "{a} {b}", false},
+		{"type changed", "{n, number}", "{n, date}", false},
+		{"select branch renamed", "{g, select, male {He} other {They}}", "{g, select, homme {Il} other {Iel}}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := parseICU(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			translation, err := parseICU(tt.translation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := compareICU(source, translation); (err == nil) != tt.ok {
+				t.Errorf("compareICU(%q, %q) = %v, want ok: %v", tt.source, tt.translation, err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package placeholder
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rules are the named patterns which can be masked. Longer syntaxes go first,
+// so "{{name}}" is one token instead of "{" and "{name}" and "}".
+var Rules = map[string]string{
+	// %s, %1$d, %@
+	"printf": printfRe.String(),
+	// {{name}} of i18next and handlebars, {{.Name}} of Go templates
+	"mustache": `\{\{.*?\}\}`,
+	// {name} of vue-i18n and simple ICU arguments
+	"braces": `\{[\w.]+\}`,
+	// $t(key) of i18next
+	"i18next-nesting": `\$t\([^()]*\)`,
+	// @:key and @.lower:key of vue-i18n
+	"vue-linked": `@(?:\.\w+)?:[\w.\-]+`,
+	// tags and entities, e.g. <b>, </a>, <br/>, &nbsp;
+	"html": `</?[a-zA-Z][^<>]*>|&(?:[a-zA-Z]+|#[0-9]+|#x[0-9a-fA-F]+);`,
+	// the target of a markdown link, the text of the link is still translated
+	"markdown-link": `\]\([^()\s]*(?:\s+"[^"]*")?\)`,
+}
+
+// DefaultRules are masked if no rules are configured
+var DefaultRules = []string{"printf", "mustache", "braces", "html"}
+
+var (
+	ruleOrder = []string{"mustache", "i18next-nesting", "vue-linked", "html", "markdown-link", "braces", "printf"}
+
+	tokenRe = regexp.MustCompile(`⟦\s*([0-9]+)\s*⟧`)
+)
+
+// Masker replaces placeholders with opaque tokens, e.g. "⟦0⟧", so the model
+// cannot change them, and restores them in the answer.
+type Masker struct {
+	re *regexp.Regexp
+}
+
+// NewMasker builds a masker from rule names and extra regular expressions
+func NewMasker(rules, patterns []string) (*Masker, error) {
+	if len(rules) == 0 && len(patterns) == 0 {
+		rules = DefaultRules
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range rules {
+		if _, ok := Rules[name]; !ok {
+			names := make([]string, 0, len(Rules))
+			for n := range Rules {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown mask rule %q, known rules are %s", name, strings.Join(names, ", "))
+		}
+		enabled[name] = true
+	}
+
+	parts := make([]string, 0)
+	// the custom patterns are the most specific
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid mask pattern %q: %w", p, err)
+		}
+		parts = append(parts, "(?:"+p+")")
+	}
+	for _, name := range ruleOrder {
+		if enabled[name] {
+			parts = append(parts, "(?:"+Rules[name]+")")
+		}
+	}
+	return &Masker{re: regexp.MustCompile(strings.Join(parts, "|"))}, nil
+}
+
+// Mask returns the text with tokens, and the masked parts in token order
+func (m *Masker) Mask(s string) (string, []string) {
+	parts := make([]string, 0)
+	masked := m.re.ReplaceAllStringFunc(s, func(part string) string {
+		parts = append(parts, part)
+		return "⟦" + strconv.Itoa(len(parts)-1) + "⟧"
+	})
+	return masked, parts
+}
+
+// Unmask puts the masked parts back. Every token must be in the text once.
+func Unmask(s string, parts []string) (string, error) {
+	seen := make([]bool, len(parts))
+	var problem error
+	result := tokenRe.ReplaceAllStringFunc(s, func(token string) string {
+		ix, _ := strconv.Atoi(tokenRe.FindStringSubmatch(token)[1])
+		switch {
+		case ix >= len(parts):
+			problem = fmt.Errorf("unknown token %s", token)
+			return token
+		case seen[ix]:
+			problem = fmt.Errorf("token %s is repeated", token)
+		}
+		seen[ix] = true
+		return parts[ix]
+	})
+	if problem != nil {
+		return "", problem
+	}
+	for ix, ok := range seen {
+		if !ok {
+			return "", fmt.Errorf("token ⟦%d⟧ for %s is missing", ix, parts[ix])
+		}
+	}
+	return result, nil
+}
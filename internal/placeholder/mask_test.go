@@ -0,0 +1,74 @@
+package placeholder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		patterns []string
+		text     string
+		masked   string
+		parts    []string
+	}{
+		{"default rules", nil, nil, "Hi {{name}}, %d <b>new</b> {x}", "Hi ⟦0⟧, ⟦1⟧ ⟦2⟧new⟦3⟧ ⟦4⟧", []string{"{{name}}", "%d", "<b>", "</b>", "{x}"}},
+		{"mustache before braces", []string{"braces", "mustache"}, nil, "{{a.b}} {c}", "⟦0⟧ ⟦1⟧", []string{"{{a.b}}", "{c}"}},
+		{"only the rules", []string{"printf"}, nil, "%s {{name}}", "⟦0⟧ {{name}}", []string{"%s"}},
+		{"nesting and linked", []string{"i18next-nesting", "vue-linked"}, nil, "$t(common.ok) @:app.name", "⟦0⟧ ⟦1⟧", []string{"$t(common.ok)", "@:app.name"}},
+		{"markdown link target", []string{"markdown-link"}, nil, "see [the docs](https://x.io/a)", "see [the docs⟦0⟧", []string{"](https://x.io/a)"}},
+		{"custom pattern", []string{"printf"}, []string{`:[a-z]+`}, "Hello :name, %s", "Hello ⟦0⟧, ⟦1⟧", []string{":name", "%s"}},
+		{"nothing to mask", nil, nil, "Hello", "Hello", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMasker(tt.rules, tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			masked, parts := m.Mask(tt.text)
+			if masked != tt.masked || !reflect.DeepEqual(parts, tt.parts) {
+				t.Errorf("Mask(%q) = %q, %q, want %q, %q", tt.text, masked, parts, tt.masked, tt.parts)
+			}
+			back, err := Unmask(masked, parts)
+			if err != nil || back != tt.text {
+				t.Errorf("Unmask(%q) = %q, %v, want %q", masked, back, err, tt.text)
+			}
+		})
+	}
+}
+
+func TestNewMaskerErrors(t *testing.T) {
+	if _, err := NewMasker([]string{"nope"}, nil); err == nil {
+		t.Error("an unknown rule is accepted")
+	}
+	if _, err := NewMasker(nil, []string{"("}); err == nil {
+		t.Error("an invalid pattern is accepted")
+	}
+}
+
+func TestUnmask(t *testing.T) {
+	parts := []string{"{{name}}", "%d"}
+	tests := []struct {
+		name string
+		text string
+		want string
+		ok   bool
+	}{
+		{"reordered", "⟦1⟧ ⟦0⟧", "%d {{name}}", true},
+		{"spaces in token", "⟦ 0 ⟧ ⟦1⟧", "{{name}} %d", true},
+		{"missing", "⟦0⟧", "", false},
+		{"repeated", "⟦0⟧ ⟦0⟧ ⟦1⟧", "", false},
+		{"unknown", "⟦0⟧ ⟦1⟧ ⟦2⟧", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unmask(tt.text, parts)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("Unmask(%q) = %q, %v, want %q, ok: %v", tt.text, got, err, tt.want, tt.ok)
+			}
+		})
+	}
+}
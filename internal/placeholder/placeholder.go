@@ -0,0 +1,155 @@
+package placeholder
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholders are the parts of a string which the code fills in, e.g.
+// "%s", "%1$d", "{{name}}", "{count}" and ICU arguments like
+// "{n, plural, one {# file} other {# files}}". A translation must keep the
+// placeholders of its source, and ICU messages must stay valid.
+
+var (
+	printfRe   = regexp.MustCompile(`%(?:([1-9][0-9]*)\$)?[-+0#']*(?:[0-9]+|\*)?(?:\.(?:[0-9]+|\*))?(?:hh|h|ll|l|L|q|j|z|t)?[diouxXeEfFgGaAcsp@]`)
+	mustacheRe = regexp.MustCompile(`\{\{-?\s*([^{}]*?)\s*-?\}\}`)
+	braceRe    = regexp.MustCompile(`\{[^{}\s]+\}`)
+)
+
+// Check reports the first problem of the placeholders of the translation,
+// compared to the source, or nil if they match.
+func Check(source, translation string) error {
+	if err := checkPrintf(source, translation); err != nil {
+		return err
+	}
+
+	srcMustache, dstMustache := mustaches(source), mustaches(translation)
+	if err := sameSet("placeholder", srcMustache, dstMustache); err != nil {
+		return err
+	}
+
+	source = mustacheRe.ReplaceAllString(source, "")
+	translation = mustacheRe.ReplaceAllString(translation, "")
+	if !strings.ContainsAny(source, "{}") {
+		// braces which the source does not have are just text
+		return sameSet("placeholder", nil, braces(translation))
+	}
+
+	srcArgs, err := parseICU(source)
+	if err != nil || !hasTypedArg(srcArgs) {
+		// simple placeholders, e.g. "{0} of {1}", where an apostrophe is only text
+		return sameSet("placeholder", braces(source), braces(translation))
+	}
+	dstArgs, err := parseICU(translation)
+	if err != nil {
+		return fmt.Errorf("invalid ICU message: %w", err)
+	}
+	return compareICU(srcArgs, dstArgs)
+}
+
+// CheckPlural checks the translation of a plural form. A form may use the
+// placeholders of another form of its text, e.g. "{{count}} файл" for
+// "One file" when the other form is "{{count}} files", so it is accepted if
+// it matches the source or one of the forms.
+func CheckPlural(source string, forms []string, translation string) error {
+	err := Check(source, translation)
+	if err == nil {
+		return nil
+	}
+	for _, form := range forms {
+		if Check(form, translation) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// checkPrintf compares the printf placeholders. Numbered ones may move, the
+// others must keep their order.
+func checkPrintf(source, translation string) error {
+	srcNumbered, srcPlain := printfSpecs(source)
+	dstNumbered, dstPlain := printfSpecs(translation)
+	if err := sameSet("placeholder", srcNumbered, dstNumbered); err != nil {
+		return err
+	}
+	if strings.Join(srcPlain, " ") != strings.Join(dstPlain, " ") {
+		if err := sameSet("placeholder", srcPlain, dstPlain); err != nil {
+			return err
+		}
+		if len(srcPlain) != len(dstPlain) {
+			return fmt.Errorf("the number of placeholders changed from %d to %d", len(srcPlain), len(dstPlain))
+		}
+		return fmt.Errorf("the order of the placeholders changed from %s to %s",
+			strings.Join(srcPlain, " "), strings.Join(dstPlain, " "))
+	}
+	return nil
+}
+
+func printfSpecs(s string) (numbered, plain []string) {
+	s = strings.ReplaceAll(s, "%%", "")
+	for _, m := range printfRe.FindAllStringSubmatch(s, -1) {
+		if m[1] != "" {
+			numbered = append(numbered, m[0])
+		} else {
+			plain = append(plain, m[0])
+		}
+	}
+	return numbered, plain
+}
+
+func mustaches(s string) []string {
+	names := make([]string, 0)
+	for _, m := range mustacheRe.FindAllStringSubmatch(s, -1) {
+		names = append(names, "{{"+m[1]+"}}")
+	}
+	return names
+}
+
+func hasTypedArg(args []icuArg) bool {
+	for _, arg := range args {
+		if arg.kind != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func braces(s string) []string {
+	return braceRe.FindAllString(s, -1)
+}
+
+// sameSet reports the first item which is only in one of the lists
+func sameSet(what string, source, translation []string) error {
+	src := toSet(source)
+	dst := toSet(translation)
+	for _, item := range sortedKeys(src) {
+		if !dst[item] {
+			return fmt.Errorf("%s %s is missing", what, item)
+		}
+	}
+	for _, item := range sortedKeys(dst) {
+		if !src[item] {
+			return fmt.Errorf("%s %s is not in the source", what, item)
+		}
+	}
+	return nil
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,64 @@
+package placeholder
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		translation string
+		ok          bool
+	}{
+		{"plain text", "Hello", "こんにちは", true},
+		{"printf kept", "%d files in %s", "%d ファイル、%s", true},
+		{"printf dropped", "%d files", "ファイル", false},
+		{"printf reordered", "%s of %d", "%d の %s", false},
+		{"numbered printf moved", "%1$s of %2$d", "%2$d の %1$s", true},
+		{"printf escaped percent", "100%% done", "100%% 完了", true},
+		{"mustache kept", "Hi {{name}}", "こんにちは {{ name }}", true},
+		{"mustache translated", "Hi {{name}}", "こんにちは {{名前}}", false},
+		{"mustache added", "Hi", "Hi {{name}}", false},
+		{"braces kept", "{0} of {1}", "{1} の {0}", true},
+		{"braces dropped", "{0} of {1}", "{0}", false},
+		{"braces in text", "Use JSON", "Utilisez {JSON}", false},
+		{"icu plural branches follow the language", "{n, plural, one {# file} other {# files}}", "{n, plural, one {# файл} few {# файла} many {# файлов} other {# файла}}", true},
+		{"icu argument renamed", "{n, plural, one {# file} other {# files}}", "{count, plural, other {# ファイル}}", false},
+		{"icu type changed", "{n, plural, other {# files}}", "{n, number} ファイル", false},
+		{"icu select branches kept", "{g, select, male {He} female {She} other {They}}", "{g, select, male {Il} female {Elle} other {Iel}}", true},
+		{"icu select branch dropped", "{g, select, male {He} female {She} other {They}}", "{g, select, other {Iel}}", false},
+		{"icu not closed", "{n, plural, other {# files}}", "{n, plural, other {# ファイル}", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.source, tt.translation)
+			if (err == nil) != tt.ok {
+				t.Errorf("Check(%q, %q) = %v, want ok: %v", tt.source, tt.translation, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCheckPlural(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		forms       []string
+		translation string
+		ok          bool
+	}{
+		{"i18next count added to one", "One file", []string{"{{count}} files"}, "{{count}} файл", true},
+		{"printf count added to the first PO form", "One file", []string{"%d files"}, "%d файл", true},
+		{"count dropped from one", "%d file", []string{"%d files"}, "Один файл", false},
+		{"same as the source", "One file", []string{"{{count}} files"}, "Один файл", true},
+		{"unknown placeholder", "One file", []string{"{{count}} files"}, "{{total}} файл", false},
+		{"no forms", "One file", nil, "{{count}} файл", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPlural(tt.source, tt.forms, tt.translation)
+			if (err == nil) != tt.ok {
+				t.Errorf("CheckPlural(%q, %q, %q) = %v, want ok: %v", tt.source, tt.forms, tt.translation, err, tt.ok)
+			}
+		})
+	}
+}